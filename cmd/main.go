@@ -1,22 +1,70 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	config "github.com/media-luna/eureka/configs"
+	"github.com/media-luna/eureka/internal/database"
+	_ "github.com/media-luna/eureka/internal/database/mysql" // Register the MySQL database
 	"github.com/media-luna/eureka/internal/eureka"
+	"github.com/media-luna/eureka/internal/fingerprint"
 	"github.com/media-luna/eureka/utils/logger"
 )
 
 func main() {
 	// Parse command line arguments
 	audioFile := flag.String("file", "", "Path to the audio file to process")
+	reingestDir := flag.String("reingest", "", "Directory of audio files to fingerprint again, for songs stored with an outdated hash version")
+	recognizeFile := flag.String("recognize", "", "Path to an audio clip to identify against the database")
+	explainCmd := flag.Bool("explain", false, "Print a JSON report of each recognition stage for the -recognize clip instead of the results")
+	curveCmd := flag.Bool("curve", false, "Print the per-second confidence of each result for the -recognize clip instead of the results")
+	plotFile := flag.String("plot", "", "Path of a PNG image of the hashes the -recognize clip shares with its results")
+	streamCmd := flag.Bool("stream", false, "Identify raw 16-bit little-endian PCM audio read from stdin")
+	streamChannels := flag.Int("channels", 1, "Number of interleaved channels in the -stream input")
+	profile := flag.String("profile", "", "Fingerprinting profile overriding the config, default or short for jingles and ad spots")
+	tempoCmd := flag.Bool("tempo", false, "Search over playback speeds when recognizing, for sped up or slowed down audio")
+	pitchCmd := flag.Bool("pitch", false, "Search over pitch shifts when recognizing, for transposed audio")
+	voteCmd := flag.Bool("vote", false, "Recognize overlapping sub-windows of the query and accept only songs most of them agree on")
+	jitterBins := flag.Int("jitter-bins", -1, "Also look up hashes this many frequency bins away from each query peak")
+	jitterFrames := flag.Int("jitter-frames", -1, "Also look up hashes this many frames away from each query time delta")
+	monitorFile := flag.String("monitor", "", "Path to a long recording to build a timeline of detected songs, or - to read PCM from stdin")
+	exportFile := flag.String("export", "", "Path to an audio clip whose fingerprints are written to -out, no database needed")
+	hashesFile := flag.String("hashes", "", "Path to a fingerprints JSON file, as written by -export, to identify against the database")
+	outFile := flag.String("out", "fingerprints.json", "Output path used by -export")
+	compareCmd := flag.Bool("compare", false, "Compare the two audio files given as arguments, no database needed")
+	syncCmd := flag.Bool("sync", false, "Compute the offsets of the audio files given as arguments relative to the first one, no database needed")
+	jsonOutput := flag.Bool("json", false, "Print the -sync offsets or the -curve confidences as JSON instead of a table")
+	repeatsFile := flag.String("repeats", "", "Path to a song whose repeated sections, like the chorus, are listed, no database needed")
+	sharedFile := flag.String("shared", "", "Path of a .json or .csv report of the songs in the database that share audio")
+	recordUnknown := flag.Bool("record-unknown", false, "Keep the fingerprints of queries that match no song to find recurring unknown content")
+	unknownCmd := flag.Bool("unknown", false, "List clusters of recurring unknown queries")
+	promoteCluster := flag.Int("promote", 0, "ID of an unknown cluster to store as a song, named with -name and -artist")
+	songName := flag.String("name", "", "Song name used by -promote")
+	artistName := flag.String("artist", "", "Artist name used by -promote")
 	listCmd := flag.Bool("list", false, "List all songs in the database")
 	cleanupCmd := flag.Bool("cleanup", false, "Clean up duplicate songs in the database")
 	deleteCmd := flag.Int("delete", -1, "Delete a song by its ID")
+	tagSong := flag.Int("tag", -1, "ID of a song to attach the -tags to")
+	tags := flag.String("tags", "", "Comma separated tags attached by -tag")
+	markSong := flag.Int("mark", -1, "ID of a song whose section markers are replaced by -markers")
+	markCatalogue := flag.String("mark-catalogue", "", "Name of the catalogue holding the song marked by -mark, as shown in the results, empty for the main database")
+	markers := flag.String("markers", "", "Comma separated name=seconds section markers set by -mark, like intro=0,chorus=45.5")
+	onlyIDs := flag.String("only-ids", "", "Comma separated song IDs recognition is restricted to")
+	excludeIDs := flag.String("exclude-ids", "", "Comma separated song IDs recognition ignores")
+	onlyArtists := flag.String("only-artists", "", "Comma separated artists recognition is restricted to")
+	excludeArtists := flag.String("exclude-artists", "", "Comma separated artists recognition ignores")
+	onlyTags := flag.String("only-tags", "", "Comma separated tags, recognition is restricted to songs with one of them")
+	excludeTags := flag.String("exclude-tags", "", "Comma separated tags, recognition ignores songs with any of them")
+	addedAfter := flag.String("added-after", "", "Restrict recognition to songs added on or after this date, as YYYY-MM-DD")
+	addedBefore := flag.String("added-before", "", "Restrict recognition to songs added before this date, as YYYY-MM-DD")
 	flag.Parse()
 
 	// Load configuration
@@ -27,6 +75,75 @@ func main() {
 		logger.Error(fmt.Errorf("failed to load configuration: %v", err))
 		os.Exit(1)
 	}
+	if *profile != "" {
+		config.Config.Profile = *profile
+	}
+	if config.Config.Profile == "" {
+		config.Config.Profile = eureka.PROFILE_DEFAULT
+	}
+
+	// Compare, sync, repeats and export do not need a database
+	offlineProfile, err := eureka.ProfileByName(*config, config.Config.Profile)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	if *compareCmd {
+		if flag.NArg() != 2 {
+			logger.Error(fmt.Errorf("-compare expects exactly two audio files as arguments"))
+			os.Exit(1)
+		}
+		comparison, err := eureka.Compare(flag.Arg(0), flag.Arg(1), offlineProfile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error comparing audio files: %v", err))
+			os.Exit(1)
+		}
+		fmt.Printf("Similarity: %.2f | Aligned hashes: %d | Hashes: %d / %d | Offset of second file in first: %.3fs\n",
+			comparison.Similarity, comparison.AlignedHashes, comparison.HashesA, comparison.HashesB, comparison.OffsetSeconds)
+		return
+	}
+
+	if *syncCmd {
+		if flag.NArg() < 2 {
+			logger.Error(fmt.Errorf("-sync expects at least two audio files as arguments, the first one is the reference"))
+			os.Exit(1)
+		}
+		offsets, err := eureka.Synchronize(flag.Args(), offlineProfile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error synchronizing audio files: %v", err))
+			os.Exit(1)
+		}
+		if err := printOffsets(offsets, *jsonOutput); err != nil {
+			logger.Error(fmt.Errorf("error printing offsets: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *repeatsFile != "" {
+		sections, err := eureka.FindRepetitionsFile(*repeatsFile, offlineProfile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error finding repeated sections: %v", err))
+			os.Exit(1)
+		}
+		printRepetitions(sections)
+		return
+	}
+
+	if *exportFile != "" {
+		fingerprints, err := eureka.FingerprintFile(*exportFile, offlineProfile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error fingerprinting audio file: %v", err))
+			os.Exit(1)
+		}
+		if err := writeFingerprints(*outFile, config.Config.Profile, fingerprints); err != nil {
+			logger.Error(fmt.Errorf("error exporting fingerprints: %v", err))
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("Exported %d fingerprints to %s", len(fingerprints), *outFile))
+		return
+	}
 
 	// Get Eureka app
 	app, err := eureka.NewEureka(*config)
@@ -35,6 +152,78 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *tempoCmd {
+		app.Config.Recognition.TempoSearch = true
+	}
+	if *pitchCmd {
+		app.Config.Recognition.PitchSearch = true
+	}
+	if *voteCmd {
+		app.Config.Recognition.Voting = true
+	}
+	if *jitterBins >= 0 {
+		app.Config.Recognition.JitterFreqBins = *jitterBins
+	}
+	if *jitterFrames >= 0 {
+		app.Config.Recognition.JitterTimeFrames = *jitterFrames
+	}
+	if *recordUnknown {
+		app.Config.Unknown.Record = true
+	}
+
+	filter, err := parseFilter(*onlyIDs, *excludeIDs, *onlyArtists, *excludeArtists, *onlyTags, *excludeTags, *addedAfter, *addedBefore)
+	if err != nil {
+		logger.Error(fmt.Errorf("invalid filter: %v", err))
+		os.Exit(1)
+	}
+	if !filter.IsEmpty() {
+		app = app.WithFilter(filter)
+	}
+
+	if *tagSong >= 0 {
+		if err := app.Tag(*tagSong, splitList(*tags)); err != nil {
+			logger.Error(fmt.Errorf("error tagging song: %v", err))
+			os.Exit(1)
+		}
+		songTags, err := app.Tags(*tagSong)
+		if err != nil {
+			logger.Error(fmt.Errorf("error loading song tags: %v", err))
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("Song %d is tagged: %s", *tagSong, strings.Join(songTags, ", ")))
+		return
+	}
+
+	if *markSong >= 0 {
+		songMarkers, err := parseMarkers(*markers)
+		if err != nil {
+			logger.Error(fmt.Errorf("invalid markers: %v", err))
+			os.Exit(1)
+		}
+		if err := app.SetMarkers(*markCatalogue, *markSong, songMarkers); err != nil {
+			logger.Error(fmt.Errorf("error setting song markers: %v", err))
+			os.Exit(1)
+		}
+		songMarkers, err = app.Markers(*markCatalogue, *markSong)
+		if err != nil {
+			logger.Error(fmt.Errorf("error loading song markers: %v", err))
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("Song %d has %d section markers", *markSong, len(songMarkers)))
+		for _, marker := range songMarkers {
+			fmt.Printf("%s %s\n", formatSeconds(float64(marker.Start)/1000), marker.Name)
+		}
+		return
+	}
+
+	if *reingestDir != "" {
+		if err := app.Reingest(*reingestDir); err != nil {
+			logger.Error(fmt.Errorf("error re-ingesting songs: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *deleteCmd >= 0 {
 		if err := app.Delete(*deleteCmd); err != nil {
 			logger.Error(fmt.Errorf("error deleting song: %v", err))
@@ -51,6 +240,43 @@ func main() {
 		return
 	}
 
+	if *sharedFile != "" {
+		// Check the format before the scan, which can take long on a large catalogue
+		if ext := strings.ToLower(filepath.Ext(*sharedFile)); ext != ".json" && ext != ".csv" {
+			logger.Error(fmt.Errorf("unsupported report format %q, expected .json or .csv", ext))
+			os.Exit(1)
+		}
+		segments, err := app.FindSharedSegments()
+		if err != nil {
+			logger.Error(fmt.Errorf("error finding shared segments: %v", err))
+			os.Exit(1)
+		}
+		if err := writeSharedSegments(*sharedFile, segments); err != nil {
+			logger.Error(fmt.Errorf("error writing shared segments report: %v", err))
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("Wrote %d shared segments to %s", len(segments), *sharedFile))
+		return
+	}
+
+	if *unknownCmd {
+		clusters, err := app.ClusterUnknown()
+		if err != nil {
+			logger.Error(fmt.Errorf("error clustering unknown queries: %v", err))
+			os.Exit(1)
+		}
+		printClusters(clusters)
+		return
+	}
+
+	if *promoteCluster > 0 {
+		if err := app.PromoteCluster(*promoteCluster, *songName, *artistName); err != nil {
+			logger.Error(fmt.Errorf("error promoting cluster: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *listCmd {
 		songs, err := app.List()
 		if err != nil {
@@ -69,8 +295,117 @@ func main() {
 		return
 	}
 
+	if *monitorFile != "" {
+		var segments []eureka.Segment
+		if *monitorFile == "-" {
+			segments, err = app.MonitorStream(os.Stdin, config.Config.SamplingRate, *streamChannels, nil)
+		} else {
+			segments, err = app.MonitorFile(*monitorFile)
+		}
+		if err != nil {
+			logger.Error(fmt.Errorf("error monitoring recording: %v", err))
+			os.Exit(1)
+		}
+		if len(segments) == 0 {
+			logger.Info("No songs detected in the recording")
+			return
+		}
+		logger.Info("Detected songs:")
+		for _, segment := range segments {
+			fmt.Printf("%s - %s | ID: %d | Name: %s | Artist: %s | Song offset: %.2fs | Confidence: %.2f | Windows: %d\n",
+				formatSeconds(segment.Start), formatSeconds(segment.End), segment.SongID, segment.SongName,
+				segment.Artist, segment.SongOffset, segment.Confidence, segment.Windows)
+			if segment.Catalogue != "" {
+				fmt.Printf("    Catalogue: %s\n", segment.Catalogue)
+			}
+		}
+		return
+	}
+
+	if *streamCmd {
+		recognizer, err := app.NewStreamRecognizer(config.Config.SamplingRate, *streamChannels)
+		if err != nil {
+			logger.Error(fmt.Errorf("error creating stream recognizer: %v", err))
+			os.Exit(1)
+		}
+		results, err := recognizer.Recognize(os.Stdin, func(results []eureka.Result) {
+			if len(results) > 0 {
+				logger.Info(fmt.Sprintf("Best candidate so far: %s (%d aligned hashes, input confidence %.2f)",
+					results[0].SongName, results[0].AlignedHashes, results[0].InputConfidence))
+			}
+			printDecision(recognizer.Decide())
+		})
+		if err != nil {
+			logger.Error(fmt.Errorf("error recognizing stream: %v", err))
+			os.Exit(1)
+		}
+		printResults(results)
+		printDecision(recognizer.Decide())
+		return
+	}
+
+	if *hashesFile != "" {
+		set, err := readFingerprints(*hashesFile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error reading fingerprints: %v", err))
+			os.Exit(1)
+		}
+		results, err := app.RecognizeFingerprints(set.Fingerprints, set.Profile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error recognizing fingerprints: %v", err))
+			os.Exit(1)
+		}
+		printResults(results)
+		return
+	}
+
+	if *recognizeFile != "" && *explainCmd {
+		explanation, err := app.Explain(*recognizeFile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error explaining recognition: %v", err))
+			os.Exit(1)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(explanation); err != nil {
+			logger.Error(fmt.Errorf("error writing explanation: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *recognizeFile != "" && *curveCmd {
+		curves, err := app.RecognizeCurve(*recognizeFile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error computing confidence curve: %v", err))
+			os.Exit(1)
+		}
+		if err := printCurves(curves, *jsonOutput); err != nil {
+			logger.Error(fmt.Errorf("error writing confidence curve: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *recognizeFile != "" {
+		results, decision, err := app.RecognizeStatus(*recognizeFile)
+		if err != nil {
+			logger.Error(fmt.Errorf("error recognizing audio file: %v", err))
+			os.Exit(1)
+		}
+		printResults(results)
+		printDecision(decision)
+		if *plotFile != "" {
+			if err := app.PlotMatches(*recognizeFile, results, *plotFile); err != nil {
+				logger.Error(fmt.Errorf("error plotting matches: %v", err))
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
 	if *audioFile == "" {
-		logger.Error(fmt.Errorf("please provide an audio file path using -file flag or use -list to see database contents"))
+		logger.Error(fmt.Errorf("please provide an audio file path using -file flag, -recognize to identify a clip or use -list to see database contents"))
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -80,3 +415,233 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// printResults prints recognition results, one line per matched song
+func printResults(results []eureka.Result) {
+	if len(results) == 0 {
+		logger.Info("No matching songs found")
+		return
+	}
+	logger.Info("Matching songs:")
+	for _, result := range results {
+		fmt.Printf("ID: %d | Name: %s | Artist: %s | Aligned hashes: %d | Score: %.1f | Offset: %.2fs | Speed: %.2fx | Pitch: %+.1f semitones | Input confidence: %.2f | Fingerprinted confidence: %.2f\n",
+			result.SongID, result.SongName, result.Artist, result.AlignedHashes, result.Score, result.OffsetSeconds,
+			result.SpeedFactor, result.Semitones, result.InputConfidence, result.FingerprintedConfidence)
+		if result.Catalogue != "" {
+			fmt.Printf("    Catalogue: %s\n", result.Catalogue)
+		}
+		if result.Windows > 0 {
+			fmt.Printf("    Agreeing sub-windows: %d/%d\n", result.WindowsAgreed, result.Windows)
+		}
+		if result.Section != nil {
+			if result.Section.EndSeconds > 0 {
+				fmt.Printf("    Section: %s (%.2fs - %.2fs)\n", result.Section.Name, result.Section.StartSeconds, result.Section.EndSeconds)
+			} else {
+				fmt.Printf("    Section: %s (from %.2fs)\n", result.Section.Name, result.Section.StartSeconds)
+			}
+		}
+	}
+}
+
+// printDecision prints the status of a query and how much more audio it needs
+func printDecision(decision eureka.Decision) {
+	if decision.Status == eureka.STATUS_NEEDS_MORE_AUDIO {
+		logger.Info(fmt.Sprintf("Status: %s, about %.1fs more audio needed after %.1fs", decision.Status, decision.MoreSeconds, decision.QuerySeconds))
+		return
+	}
+	logger.Info(fmt.Sprintf("Status: %s after %.1fs of audio", decision.Status, decision.QuerySeconds))
+}
+
+// printOffsets prints synchronization offsets as a table or as JSON
+func printOffsets(offsets []eureka.SyncOffset, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(offsets)
+	}
+
+	fmt.Printf("%-40s %14s %14s %8s %12s %12s\n", "File", "Offset (s)", "Offset (smp)", "Hashes", "Correlation", "Drift (ppm)")
+	for _, offset := range offsets {
+		fmt.Printf("%-40s %14.6f %14d %8d %12.3f %12.1f\n", offset.Path, offset.OffsetSeconds, offset.OffsetSamples,
+			offset.AlignedHashes, offset.Correlation, offset.DriftPPM)
+	}
+	return nil
+}
+
+// printCurves prints confidence curves as a table with one row per second
+// and one column per result, or as JSON
+func printCurves(curves []eureka.ConfidenceCurve, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(curves)
+	}
+
+	if len(curves) == 0 {
+		logger.Info("No matching songs found")
+		return nil
+	}
+
+	for i, curve := range curves {
+		fmt.Printf("#%d: ID: %d | Name: %s | Artist: %s | Offset: %.2fs\n",
+			i+1, curve.Result.SongID, curve.Result.SongName, curve.Result.Artist, curve.Result.OffsetSeconds)
+	}
+	fmt.Printf("%-10s", "Time")
+	for i := range curves {
+		fmt.Printf(" %8s", fmt.Sprintf("#%d", i+1))
+	}
+	fmt.Println()
+	for second := range curves[0].Confidence {
+		fmt.Printf("%-10s", formatSeconds(float64(second)))
+		for _, curve := range curves {
+			fmt.Printf(" %7.0f%%", curve.Confidence[second]*100)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// printClusters prints clusters of unknown queries with every occurrence
+func printClusters(clusters []eureka.UnknownCluster) {
+	if len(clusters) == 0 {
+		logger.Info("No recurring unknown content found")
+		return
+	}
+	for _, cluster := range clusters {
+		fmt.Printf("Cluster %d | Occurrences: %d\n", cluster.ID, len(cluster.Queries))
+		for _, query := range cluster.Queries {
+			fmt.Printf("    %s | %s at %s\n", query.RecordedAt.Format("2006-01-02 15:04:05"), query.Source, formatSeconds(query.Position))
+		}
+	}
+}
+
+// printRepetitions prints repeated sections, one line per occurrence
+func printRepetitions(sections []eureka.RepeatedSection) {
+	if len(sections) == 0 {
+		logger.Info("No repeated sections found")
+		return
+	}
+	for i, section := range sections {
+		fmt.Printf("Section %d | Occurrences: %d | Duration: %.1fs | Aligned hashes: %d\n",
+			i+1, len(section.Occurrences), section.Duration(), section.AlignedHashes)
+		for _, occurrence := range section.Occurrences {
+			fmt.Printf("    %s - %s (%.2fs - %.2fs)\n", formatSeconds(occurrence.Start), formatSeconds(occurrence.End),
+				occurrence.Start, occurrence.End)
+		}
+	}
+}
+
+// parseFilter builds a recognition filter from the filter flags
+func parseFilter(onlyIDs, excludeIDs, onlyArtists, excludeArtists, onlyTags, excludeTags, addedAfter, addedBefore string) (database.Filter, error) {
+	var filter database.Filter
+	var err error
+
+	if filter.SongIDs, err = parseIDs(onlyIDs); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeSongIDs, err = parseIDs(excludeIDs); err != nil {
+		return filter, err
+	}
+	filter.Artists = splitList(onlyArtists)
+	filter.ExcludeArtists = splitList(excludeArtists)
+	filter.Tags = splitList(onlyTags)
+	filter.ExcludeTags = splitList(excludeTags)
+
+	if addedAfter != "" {
+		if filter.AddedAfter, err = time.ParseInLocation("2006-01-02", addedAfter, time.Local); err != nil {
+			return filter, fmt.Errorf("invalid date %q: %v", addedAfter, err)
+		}
+	}
+	if addedBefore != "" {
+		if filter.AddedBefore, err = time.ParseInLocation("2006-01-02", addedBefore, time.Local); err != nil {
+			return filter, fmt.Errorf("invalid date %q: %v", addedBefore, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseIDs parses a comma separated list of song IDs
+func parseIDs(list string) ([]int, error) {
+	var ids []int
+	for _, item := range splitList(list) {
+		id, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid song ID %q", item)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseMarkers parses comma separated name=seconds section markers
+func parseMarkers(list string) ([]database.Marker, error) {
+	var markers []database.Marker
+	for _, item := range splitList(list) {
+		name, start, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("marker %q is not name=seconds", item)
+		}
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(start), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of marker %q: %v", item, err)
+		}
+		markers = append(markers, database.Marker{Name: strings.TrimSpace(name), Start: int(math.Round(seconds * 1000))})
+	}
+	return markers, nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// formatSeconds formats a position in seconds as HH:MM:SS
+func formatSeconds(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, (total/60)%60, total%60)
+}
+
+// writeFingerprints writes fingerprints made with the named profile to a JSON
+// file
+func writeFingerprints(path string, profile string, fingerprints []fingerprint.Fingerprint) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return fingerprint.WriteFingerprints(f, profile, fingerprints)
+}
+
+// writeSharedSegments writes a shared segments report, as JSON or CSV
+// depending on the file extension
+func writeSharedSegments(path string, segments []eureka.SharedSegment) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return eureka.WriteSharedSegmentsJSON(f, segments)
+	}
+	return eureka.WriteSharedSegmentsCSV(f, segments)
+}
+
+// readFingerprints reads fingerprints from a JSON file
+func readFingerprints(path string) (fingerprint.FingerprintSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fingerprint.FingerprintSet{}, err
+	}
+	defer f.Close()
+
+	return fingerprint.ReadFingerprints(f)
+}
@@ -28,6 +28,9 @@ type Tables struct {
 			Fingerprinted string `yaml:"fingerprinted"`
 			FileSHA1      string `yaml:"file_sha1"`
 			TotalHashes   string `yaml:"total_hashes"`
+			HashVersion   string `yaml:"hash_version"`
+			Profile       string `yaml:"profile"`
+			DateCreated   string `yaml:"date_created"`
 		} `yaml:"fields"`
 	} `yaml:"songs"`
 
@@ -38,6 +41,45 @@ type Tables struct {
 			Offset string `yaml:"offset"`
 		} `yaml:"fields"`
 	} `yaml:"fingerprints"`
+
+	HashStats struct {
+		Name   string `yaml:"name"`
+		Fields struct {
+			Hash      string `yaml:"hash"`
+			SongCount string `yaml:"song_count"`
+		} `yaml:"fields"`
+	} `yaml:"hash_stats"`
+
+	SongTags struct {
+		Name   string `yaml:"name"`
+		Fields struct {
+			Tag string `yaml:"tag"`
+		} `yaml:"fields"`
+	} `yaml:"song_tags"`
+
+	SongMarkers struct {
+		Name   string `yaml:"name"`
+		Fields struct {
+			Name  string `yaml:"name"`
+			Start string `yaml:"start"`
+		} `yaml:"fields"`
+	} `yaml:"song_markers"`
+
+	UnknownQueries struct {
+		Name   string `yaml:"name"`
+		Fields struct {
+			ID          string `yaml:"id"`
+			Source      string `yaml:"source"`
+			Position    string `yaml:"position"`
+			HashVersion string `yaml:"hash_version"`
+			Profile     string `yaml:"profile"`
+			RecordedAt  string `yaml:"recorded_at"`
+		} `yaml:"fields"`
+	} `yaml:"unknown_queries"`
+
+	UnknownFingerprints struct {
+		Name string `yaml:"name"`
+	} `yaml:"unknown_fingerprints"`
 }
 
 // Config represents the main application configuration
@@ -57,14 +99,72 @@ type Config struct {
 		PeakSort             bool    `yaml:"peak_sort"`
 		FingerprintReduction int     `yaml:"fingerprint_reduction"`
 		FingerprintLimit     int     `yaml:"fingerprint_limit"`
+		Profile              string  `yaml:"profile"`
 	} `yaml:"config"`
 
 	Recognition struct {
-		TopResults int `yaml:"top_results"`
+		TopResults              int     `yaml:"top_results"`
+		StreamSegmentSeconds    float64 `yaml:"stream_segment_seconds"`
+		StreamWindowSeconds     float64 `yaml:"stream_window_seconds"`
+		TempoSearch             bool    `yaml:"tempo_search"`
+		TempoMaxChange          float64 `yaml:"tempo_max_change"`
+		TempoStep               float64 `yaml:"tempo_step"`
+		PitchSearch             bool    `yaml:"pitch_search"`
+		PitchMaxSemitones       float64 `yaml:"pitch_max_semitones"`
+		PitchStepSemitones      float64 `yaml:"pitch_step_semitones"`
+		JitterFreqBins          int     `yaml:"jitter_freq_bins"`
+		JitterTimeFrames        int     `yaml:"jitter_time_frames"`
+		IDFWeighting            bool    `yaml:"idf_weighting"`
+		IDFMaxSongRatio         float64 `yaml:"idf_max_song_ratio"`
+		Voting                  bool    `yaml:"voting"`
+		VoteWindowSeconds       float64 `yaml:"vote_window_seconds"`
+		VoteHopSeconds          float64 `yaml:"vote_hop_seconds"`
+		VoteMinWindows          int     `yaml:"vote_min_windows"`
+		CacheSize               int     `yaml:"cache_size"`
+		CatalogueTimeoutSeconds float64 `yaml:"catalogue_timeout_seconds"`
+		StatusMinAlignedHashes  int     `yaml:"status_min_aligned_hashes"`
+		StatusMinMargin         float64 `yaml:"status_min_margin"`
+		StatusMinSeconds        float64 `yaml:"status_min_seconds"`
+		StatusMaxSeconds        float64 `yaml:"status_max_seconds"`
 	} `yaml:"recognition"`
 
+	Monitor struct {
+		WindowSeconds    float64 `yaml:"window_seconds"`
+		HopSeconds       float64 `yaml:"hop_seconds"`
+		MinAlignedHashes int     `yaml:"min_aligned_hashes"`
+		MaxMissedWindows int     `yaml:"max_missed_windows"`
+	} `yaml:"monitor"`
+
+	Unknown struct {
+		Record           bool    `yaml:"record"`
+		MinAlignedHashes int     `yaml:"min_aligned_hashes"`
+		MinSimilarity    float64 `yaml:"min_similarity"`
+		MinOccurrences   int     `yaml:"min_occurrences"`
+	} `yaml:"unknown"`
+
+	ShortReference struct {
+		PeakThreshold           float64 `yaml:"peak_threshold"`
+		FanValue                int     `yaml:"fan_value"`
+		MaxHashTimeDelta        int     `yaml:"max_hash_time_delta"`
+		MonitorWindowSeconds    float64 `yaml:"monitor_window_seconds"`
+		MonitorHopSeconds       float64 `yaml:"monitor_hop_seconds"`
+		MonitorMinAlignedHashes int     `yaml:"monitor_min_aligned_hashes"`
+		StatusMinAlignedHashes  int     `yaml:"status_min_aligned_hashes"`
+		StatusMaxSeconds        float64 `yaml:"status_max_seconds"`
+	} `yaml:"short_reference"`
+
 	Database DBConfig `yaml:"database"`
 	Tables   Tables   `yaml:"tables"`
+
+	// Catalogues lists the databases searched by recognition, songs are still
+	// saved to Database. Recognition only uses Database when none are listed.
+	Catalogues []CatalogueConfig `yaml:"catalogues"`
+}
+
+// CatalogueConfig represents a named database searched by recognition
+type CatalogueConfig struct {
+	Name     string   `yaml:"name"`
+	Database DBConfig `yaml:"database"`
 }
 
 // LoadConfig loads configuration from a YAML file
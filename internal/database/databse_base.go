@@ -1,12 +1,47 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	config "github.com/media-luna/eureka/configs"
-	"github.com/media-luna/eureka/internal/database/mysql"
+	"github.com/media-luna/eureka/internal/fingerprint"
 )
 
+// Song represents a song record from the database
+type Song struct {
+	ID            int
+	Name          string
+	Artist        string
+	Fingerprinted bool
+	FileSHA1      string
+	TotalHashes   int
+	HashVersion   int    // Version of the hashing scheme the song was fingerprinted with
+	Profile       string // Fingerprinting profile the song was fingerprinted with
+	DateCreated   string
+}
+
+// Marker names the section of a song starting at Start, the section lasts
+// until the next marker or the end of the song
+type Marker struct {
+	Name  string
+	Start int // Start of the section, in milliseconds
+}
+
+// UnknownQuery is a query that matched no song, kept so that recurring
+// unknown content can be found later
+type UnknownQuery struct {
+	ID           int
+	Source       string    // File or stream the query was heard in
+	Position     float64   // Start of the query in its source, in seconds
+	HashVersion  int       // Version of the hashing scheme the fingerprints were made with
+	Profile      string    // Fingerprinting profile the fingerprints were made with
+	RecordedAt   time.Time // When the query was recorded as unknown
+	Fingerprints []fingerprint.Fingerprint
+}
+
 // Database defines the interface that all database implementations must satisfy
 type Database interface {
 	Setup() error
@@ -14,29 +49,67 @@ type Database interface {
 	// BeforeFork()
 	// AfterFork()
 	// Empty()
-	// GetNumSongs() int
+	GetNumSongs(ctx context.Context, filter Filter) (int, error)
 	// GetNumFingerprints() int
 	// SetSongFingerprinted(songID int)
 	// GetSongs() []map[string]string
-	// GetSongByID(songID int) map[string]string
+	GetSongByID(songID int) (Song, error)
+	GetSongsByIDs(ctx context.Context, songIDs []int) (map[int]Song, error)
+	ListSongs() ([]Song, error)
 	InsertFingerprints(fingerprint string, songID int, offset int) error
 	InsertSong(songName string, artistName string, fileHash string, totalHashes int) (int, error)
 	DeleteSong(songID int) error
+	DeleteSongFingerprints(songID int) error
 	// Qurey(fingerprint string) []string
 	// GetIterableKVPairs() []string
 	// InsetHashes(songID int, hashes []map[string]int, batchSize int)
-	// ReturnMatches(hashes []map[string]int, batchSize int) []map[string]string
+	ReturnMatches(ctx context.Context, hashes []string, filter Filter) ([]fingerprint.Fingerprint, error)
+	GetSongFingerprints(songID int) ([]fingerprint.Fingerprint, error)
+	AddSongTags(songID int, tags []string) error
+	GetSongTags(songID int) ([]string, error)
+	SetSongMarkers(songID int, markers []Marker) error
+	GetSongMarkers(songID int) ([]Marker, error)
+	GetSongsMarkers(ctx context.Context, songIDs []int) (map[int][]Marker, error)
+	GetHashSongCounts(ctx context.Context, hashes []string, filter Filter) (map[string]int, error)
 	// DeleteSongById(songIDs []int, batchSize int)
-	UpdateSongFingerprinted(songID int) error
+	UpdateSongFingerprinted(songID int, hashVersion int, profile string) error
+	GetNumOutdatedSongs(hashVersion int) (int, error)
+	GetProfileSongCounts() (map[string]int, error)
+	InsertUnknownQuery(query UnknownQuery) (int, error)
+	GetUnknownQueries(hashVersion int, profile string) ([]UnknownQuery, error)
+	DeleteUnknownQueries(queryIDs []int) error
 	Cleanup() error
 }
 
+// Opener opens a connection to a database of a given type
+type Opener func(cfg config.Config) (Database, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = make(map[string]Opener)
+)
+
+// Register makes a database type available to NewDatabase. It is called from
+// the init function of each database implementation, and panics when the same
+// type is registered twice.
+func Register(dbType string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+
+	if _, exists := openers[dbType]; exists {
+		panic(fmt.Sprintf("database type %s registered twice", dbType))
+	}
+	openers[dbType] = open
+}
+
 // NewDatabase creates a new database instance based on the configuration
 func NewDatabase(cfg config.Config) (Database, error) {
-	switch cfg.Database.Type {
-	case "mysql":
-		return mysql.NewDB(cfg)
-	default:
+	openersMu.RLock()
+	open, ok := openers[cfg.Database.Type]
+	openersMu.RUnlock()
+
+	if !ok {
 		return nil, fmt.Errorf("unsupported database type: %s", cfg.Database.Type)
 	}
+	return open(cfg)
 }
@@ -0,0 +1,25 @@
+package database
+
+import "time"
+
+// Filter restricts the songs considered during recognition. Empty fields do
+// not filter, and a song must pass every non-empty field to be considered.
+type Filter struct {
+	SongIDs        []int     // Only consider these songs
+	ExcludeSongIDs []int     // Never consider these songs
+	Artists        []string  // Only consider songs by one of these artists
+	ExcludeArtists []string  // Never consider songs by these artists
+	Tags           []string  // Only consider songs with at least one of these tags
+	ExcludeTags    []string  // Never consider songs with any of these tags
+	AddedAfter     time.Time // Only consider songs added at or after this time
+	AddedBefore    time.Time // Only consider songs added before this time
+	Profile        string    // Only consider songs fingerprinted with this profile
+}
+
+// IsEmpty reports whether the filter lets every song through
+func (f Filter) IsEmpty() bool {
+	return len(f.SongIDs) == 0 && len(f.ExcludeSongIDs) == 0 &&
+		len(f.Artists) == 0 && len(f.ExcludeArtists) == 0 &&
+		len(f.Tags) == 0 && len(f.ExcludeTags) == 0 &&
+		f.AddedAfter.IsZero() && f.AddedBefore.IsZero() && f.Profile == ""
+}
@@ -1,11 +1,15 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	config "github.com/media-luna/eureka/configs"
+	"github.com/media-luna/eureka/internal/database"
+	"github.com/media-luna/eureka/internal/fingerprint"
 	"github.com/media-luna/eureka/utils/logger"
 )
 
@@ -15,17 +19,6 @@ type DB struct {
 	cfg  config.Config
 }
 
-// Song represents a song record from the database
-type Song struct {
-	ID            int
-	Name          string
-	Artist        string
-	Fingerprinted bool
-	FileSHA1      string
-	TotalHashes   int
-	DateCreated   string
-}
-
 const (
 	createSongsTableSQL = `
 		CREATE TABLE IF NOT EXISTS %s (
@@ -35,7 +28,9 @@ const (
 			%s TINYINT DEFAULT 0,
 			%s BINARY(20) NOT NULL,
 			%s INT NOT NULL DEFAULT 0,
-			date_created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			%s TINYINT UNSIGNED NOT NULL DEFAULT %d,
+			%s VARCHAR(16) NOT NULL DEFAULT '%s',
+			%s DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			date_modified DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			PRIMARY KEY (%s),
 			UNIQUE KEY file_sha1_idx (%s)
@@ -54,9 +49,69 @@ const (
 				REFERENCES %s(%s) ON DELETE CASCADE
 		) ENGINE=INNODB;`
 
+	createHashStatsTableSQL = `
+		CREATE TABLE IF NOT EXISTS %s (
+			%s BINARY(10) NOT NULL,
+			%s INT UNSIGNED NOT NULL DEFAULT 0,
+			PRIMARY KEY (%s)
+		) ENGINE=INNODB;`
+
+	createSongTagsTableSQL = `
+		CREATE TABLE IF NOT EXISTS %s (
+			%s MEDIUMINT UNSIGNED NOT NULL,
+			%s VARCHAR(100) NOT NULL,
+			PRIMARY KEY (%s, %s),
+			INDEX ix_%s_%s (%s),
+			CONSTRAINT fk_%s_%s FOREIGN KEY (%s)
+				REFERENCES %s(%s) ON DELETE CASCADE
+		) ENGINE=INNODB;`
+
+	createSongMarkersTableSQL = `
+		CREATE TABLE IF NOT EXISTS %s (
+			%s MEDIUMINT UNSIGNED NOT NULL,
+			%s VARCHAR(100) NOT NULL,
+			%s INT UNSIGNED NOT NULL,
+			PRIMARY KEY (%s, %s),
+			CONSTRAINT fk_%s_%s FOREIGN KEY (%s)
+				REFERENCES %s(%s) ON DELETE CASCADE
+		) ENGINE=INNODB;`
+
+	createUnknownQueriesTableSQL = `
+		CREATE TABLE IF NOT EXISTS %s (
+			%s INT UNSIGNED NOT NULL AUTO_INCREMENT,
+			%s VARCHAR(500) NOT NULL DEFAULT '',
+			%s DOUBLE NOT NULL DEFAULT 0,
+			%s TINYINT UNSIGNED NOT NULL,
+			%s VARCHAR(16) NOT NULL DEFAULT '%s',
+			%s DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			PRIMARY KEY (%s)
+		) ENGINE=INNODB;`
+
+	createUnknownFingerprintsTableSQL = `
+		CREATE TABLE IF NOT EXISTS %s (
+			%s INT UNSIGNED NOT NULL,
+			%s BINARY(10) NOT NULL,
+			%s INT UNSIGNED NOT NULL,
+			INDEX ix_%s_%s (%s),
+			CONSTRAINT fk_%s_%s FOREIGN KEY (%s)
+				REFERENCES %s(%s) ON DELETE CASCADE
+		) ENGINE=INNODB;`
+
 	deleteUnfingerprintedSQL = `DELETE FROM %s WHERE %s = 0;`
+
+	matchBatchSize = 1000 // Max number of hashes looked up in a single query
+
+	legacyHashVersion = 1         // Hash version of songs fingerprinted before the version was stored
+	legacyProfile     = "default" // Fingerprinting profile of rows stored before the profile was recorded
 )
 
+// Make MySQL available to database.NewDatabase
+func init() {
+	database.Register("mysql", func(cfg config.Config) (database.Database, error) {
+		return NewDB(cfg)
+	})
+}
+
 // NewDB creates a new DB instance with the given configuration.
 func NewDB(cfg config.Config) (*DB, error) {
 	db := &DB{cfg: cfg}
@@ -101,6 +156,11 @@ func (m *DB) Setup() error {
 		m.cfg.Tables.Songs.Fields.Fingerprinted,
 		m.cfg.Tables.Songs.Fields.FileSHA1,
 		m.cfg.Tables.Songs.Fields.TotalHashes,
+		m.cfg.Tables.Songs.Fields.HashVersion,
+		legacyHashVersion,
+		m.cfg.Tables.Songs.Fields.Profile,
+		legacyProfile,
+		m.cfg.Tables.Songs.Fields.DateCreated,
 		m.cfg.Tables.Songs.Fields.ID,
 		m.cfg.Tables.Songs.Fields.FileSHA1)
 
@@ -108,6 +168,18 @@ func (m *DB) Setup() error {
 		return fmt.Errorf("error creating songs table: %w", err)
 	}
 
+	// Songs tables created before the hash version was stored
+	if err := m.addColumn(m.cfg.Tables.Songs.Name, m.cfg.Tables.Songs.Fields.HashVersion,
+		fmt.Sprintf("TINYINT UNSIGNED NOT NULL DEFAULT %d", legacyHashVersion)); err != nil {
+		return err
+	}
+
+	// Songs tables created before the fingerprinting profile was stored
+	if err := m.addColumn(m.cfg.Tables.Songs.Name, m.cfg.Tables.Songs.Fields.Profile,
+		fmt.Sprintf("VARCHAR(16) NOT NULL DEFAULT '%s'", legacyProfile)); err != nil {
+		return err
+	}
+
 	// Create fingerprints table
 	fpSQL := fmt.Sprintf(createFingerprintsTableSQL,
 		m.cfg.Tables.Fingerprints.Name,
@@ -134,6 +206,135 @@ func (m *DB) Setup() error {
 		return fmt.Errorf("error creating fingerprints table: %w", err)
 	}
 
+	// Create hash stats table
+	statsSQL := fmt.Sprintf(createHashStatsTableSQL,
+		m.cfg.Tables.HashStats.Name,
+		m.cfg.Tables.HashStats.Fields.Hash,
+		m.cfg.Tables.HashStats.Fields.SongCount,
+		m.cfg.Tables.HashStats.Fields.Hash)
+
+	if _, err := m.conn.Exec(statsSQL); err != nil {
+		return fmt.Errorf("error creating hash stats table: %w", err)
+	}
+
+	// Create song tags table
+	tagsSQL := fmt.Sprintf(createSongTagsTableSQL,
+		m.cfg.Tables.SongTags.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongTags.Fields.Tag,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongTags.Fields.Tag,
+		m.cfg.Tables.SongTags.Name,
+		m.cfg.Tables.SongTags.Fields.Tag,
+		m.cfg.Tables.SongTags.Fields.Tag,
+		m.cfg.Tables.SongTags.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	if _, err := m.conn.Exec(tagsSQL); err != nil {
+		return fmt.Errorf("error creating song tags table: %w", err)
+	}
+
+	// Create song markers table
+	markersSQL := fmt.Sprintf(createSongMarkersTableSQL,
+		m.cfg.Tables.SongMarkers.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongMarkers.Fields.Name,
+		m.cfg.Tables.SongMarkers.Fields.Start,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongMarkers.Fields.Start,
+		m.cfg.Tables.SongMarkers.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	if _, err := m.conn.Exec(markersSQL); err != nil {
+		return fmt.Errorf("error creating song markers table: %w", err)
+	}
+
+	// Create unknown queries table
+	unknownSQL := fmt.Sprintf(createUnknownQueriesTableSQL,
+		m.cfg.Tables.UnknownQueries.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Fields.Source,
+		m.cfg.Tables.UnknownQueries.Fields.Position,
+		m.cfg.Tables.UnknownQueries.Fields.HashVersion,
+		m.cfg.Tables.UnknownQueries.Fields.Profile,
+		legacyProfile,
+		m.cfg.Tables.UnknownQueries.Fields.RecordedAt,
+		m.cfg.Tables.UnknownQueries.Fields.ID)
+
+	if _, err := m.conn.Exec(unknownSQL); err != nil {
+		return fmt.Errorf("error creating unknown queries table: %w", err)
+	}
+
+	// Unknown queries tables created before the fingerprinting profile was stored
+	if err := m.addColumn(m.cfg.Tables.UnknownQueries.Name, m.cfg.Tables.UnknownQueries.Fields.Profile,
+		fmt.Sprintf("VARCHAR(16) NOT NULL DEFAULT '%s'", legacyProfile)); err != nil {
+		return err
+	}
+
+	// Create unknown fingerprints table
+	unknownFPSQL := fmt.Sprintf(createUnknownFingerprintsTableSQL,
+		m.cfg.Tables.UnknownFingerprints.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.Fingerprints.Fields.Offset,
+		m.cfg.Tables.UnknownFingerprints.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownFingerprints.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID)
+
+	if _, err := m.conn.Exec(unknownFPSQL); err != nil {
+		return fmt.Errorf("error creating unknown fingerprints table: %w", err)
+	}
+
+	// Compute the stats of catalogues fingerprinted before the table existed
+	var statsEmpty, fingerprintsEmpty bool
+	emptyQuery := fmt.Sprintf("SELECT NOT EXISTS (SELECT 1 FROM %s), NOT EXISTS (SELECT 1 FROM %s)",
+		m.cfg.Tables.HashStats.Name,
+		m.cfg.Tables.Fingerprints.Name)
+
+	if err := m.conn.QueryRow(emptyQuery).Scan(&statsEmpty, &fingerprintsEmpty); err != nil {
+		return fmt.Errorf("error checking hash stats table: %w", err)
+	}
+
+	if statsEmpty && !fingerprintsEmpty {
+		logger.Info("Computing hash stats from existing fingerprints")
+		if err := m.RebuildHashStats(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumn adds a column to an existing table unless it is already there
+func (m *DB) addColumn(table string, column string, definition string) error {
+	existsQuery := `SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+
+	var count int
+	if err := m.conn.QueryRow(existsQuery, table, column).Scan(&count); err != nil {
+		return fmt.Errorf("error checking column %s.%s: %w", table, column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("Adding column %s to table %s", column, table))
+	alterQuery := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := m.conn.Exec(alterQuery); err != nil {
+		return fmt.Errorf("error adding column %s.%s: %w", table, column, err)
+	}
+
 	return nil
 }
 
@@ -203,7 +404,8 @@ func (m *DB) InsertSong(songName string, artistName string, fileHash string, tot
 	return int(id), err
 }
 
-// Insert fingerprints into fingerprints table
+// Insert fingerprints into fingerprints table. The hash stats are updated
+// once all the fingerprints of the song are stored, see UpdateSongFingerprinted.
 func (m *DB) InsertFingerprints(fingerprint string, songID int, offset int) error {
 	query := fmt.Sprintf("INSERT IGNORE INTO %s (%s, %s, %s) VALUES (?, UNHEX(?), ?)",
 		m.cfg.Tables.Fingerprints.Name,
@@ -215,45 +417,256 @@ func (m *DB) InsertFingerprints(fingerprint string, songID int, offset int) erro
 	return err
 }
 
-// UpdateSongFingerprinted marks a song as fingerprinted in the database
-func (m *DB) UpdateSongFingerprinted(songID int) error {
-	// First check if the song exists
-	checkQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?",
+// RebuildHashStats recomputes the number of songs holding each hash from the
+// fingerprints table, in a transaction so that queries never see the stats
+// half rebuilt
+func (m *DB) RebuildHashStats() error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s", m.cfg.Tables.HashStats.Name)
+	if _, err := tx.Exec(deleteQuery); err != nil {
+		return fmt.Errorf("error clearing hash stats: %w", err)
+	}
+
+	// Only fingerprinted songs are counted, see UpdateSongFingerprinted
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s (%s, %s)
+		SELECT f.%s, COUNT(DISTINCT f.%s) FROM %s f
+		INNER JOIN %s s ON s.%s = f.%s
+		WHERE s.%s = 1
+		GROUP BY f.%s`,
+		m.cfg.Tables.HashStats.Name,
+		m.cfg.Tables.HashStats.Fields.Hash,
+		m.cfg.Tables.HashStats.Fields.SongCount,
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Fingerprints.Name,
 		m.cfg.Tables.Songs.Name,
-		m.cfg.Tables.Songs.Fields.ID)
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Fingerprints.Fields.Hash)
+
+	if _, err := tx.Exec(insertQuery); err != nil {
+		return fmt.Errorf("error computing hash stats: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing hash stats: %w", err)
+	}
+
+	return nil
+}
+
+// GetNumSongs returns the number of fingerprinted songs passing the filter
+func (m *DB) GetNumSongs(ctx context.Context, filter database.Filter) (int, error) {
+	filterSQL, filterArgs := m.filterClause(filter)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = 1%s",
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		filterSQL)
 
 	var count int
-	err := m.conn.QueryRow(checkQuery, songID).Scan(&count)
+	if err := m.conn.QueryRowContext(ctx, query, filterArgs...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting songs: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetNumOutdatedSongs returns the number of fingerprinted songs whose hashes
+// were computed with a version older than hashVersion
+func (m *DB) GetNumOutdatedSongs(hashVersion int) (int, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = 1 AND %s < ?",
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Fields.HashVersion)
+
+	var count int
+	if err := m.conn.QueryRow(query, hashVersion).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting outdated songs: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetProfileSongCounts returns the number of fingerprinted songs of every
+// fingerprinting profile
+func (m *DB) GetProfileSongCounts() (map[string]int, error) {
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM %s WHERE %s = 1 GROUP BY %s",
+		m.cfg.Tables.Songs.Fields.Profile,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Fields.Profile)
+
+	rows, err := m.conn.Query(query)
 	if err != nil {
-		return fmt.Errorf("error checking if song exists: %w", err)
+		return nil, fmt.Errorf("error counting songs by profile: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var profile string
+		var count int
+		if err := rows.Scan(&profile, &count); err != nil {
+			return nil, fmt.Errorf("error scanning profile count row: %w", err)
+		}
+		counts[profile] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetHashSongCounts returns, for each of the given hashes held by a song
+// passing the filter, the number of such songs holding it. The counts come
+// from the hash stats table when the filter is empty, and are computed from
+// the fingerprints of the filtered songs otherwise.
+func (m *DB) GetHashSongCounts(ctx context.Context, hashes []string, filter database.Filter) (map[string]int, error) {
+	counts := make(map[string]int, len(hashes))
+	filterSQL, filterArgs := m.filterClause(filter)
+
+	for start := 0; start < len(hashes); start += matchBatchSize {
+		end := start + matchBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		batch := hashes[start:end]
+
+		hashPlaceholders := strings.TrimSuffix(strings.Repeat("UNHEX(?), ", len(batch)), ", ")
+		query := fmt.Sprintf("SELECT LOWER(HEX(%s)), %s FROM %s WHERE %s IN (%s)",
+			m.cfg.Tables.HashStats.Fields.Hash,
+			m.cfg.Tables.HashStats.Fields.SongCount,
+			m.cfg.Tables.HashStats.Name,
+			m.cfg.Tables.HashStats.Fields.Hash,
+			hashPlaceholders)
+		if !filter.IsEmpty() {
+			query = fmt.Sprintf("SELECT LOWER(HEX(%s)), COUNT(DISTINCT %s) FROM %s WHERE %s IN (%s)%s GROUP BY %s",
+				m.cfg.Tables.Fingerprints.Fields.Hash,
+				m.cfg.Tables.Songs.Fields.ID,
+				m.cfg.Tables.Fingerprints.Name,
+				m.cfg.Tables.Fingerprints.Fields.Hash,
+				hashPlaceholders,
+				filterSQL,
+				m.cfg.Tables.Fingerprints.Fields.Hash)
+		}
+
+		args := make([]interface{}, len(batch), len(batch)+len(filterArgs))
+		for i, hash := range batch {
+			args[i] = hash
+		}
+		if !filter.IsEmpty() {
+			args = append(args, filterArgs...)
+		}
+
+		rows, err := m.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("error querying hash stats: %w", err)
+		}
+
+		for rows.Next() {
+			var hash string
+			var count int
+			if err := rows.Scan(&hash, &count); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("error scanning hash stats row: %w", err)
+			}
+			counts[hash] = count
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error iterating hash stats rows: %w", err)
+		}
+		rows.Close()
 	}
 
-	if count == 0 {
+	return counts, nil
+}
+
+// UpdateSongFingerprinted marks a song as fingerprinted in the database with
+// the hash version and profile its fingerprints were computed with, and counts its stored
+// fingerprints since a re-ingested song keeps the count of its first insert.
+// The song is added to the stats of its hashes in the same transaction, with
+// a single statement over all its fingerprints, the first time it is marked.
+func (m *DB) UpdateSongFingerprinted(songID int, hashVersion int, profile string) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// First check if the song exists
+	checkQuery := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ? FOR UPDATE",
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	var fingerprinted bool
+	err = tx.QueryRow(checkQuery, songID).Scan(&fingerprinted)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("song with ID %d not found", songID)
 	}
+	if err != nil {
+		return fmt.Errorf("error checking if song exists: %w", err)
+	}
 
 	// Song exists, update it
-	updateQuery := fmt.Sprintf("UPDATE %s SET %s = 1 WHERE %s = ?",
+	updateQuery := fmt.Sprintf("UPDATE %s SET %s = 1, %s = ?, %s = ?, %s = (SELECT COUNT(*) FROM %s WHERE %s = ?) WHERE %s = ?",
 		m.cfg.Tables.Songs.Name,
 		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Fields.HashVersion,
+		m.cfg.Tables.Songs.Fields.Profile,
+		m.cfg.Tables.Songs.Fields.TotalHashes,
+		m.cfg.Tables.Fingerprints.Name,
+		m.cfg.Tables.Songs.Fields.ID,
 		m.cfg.Tables.Songs.Fields.ID)
 
-	_, err = m.conn.Exec(updateQuery, songID)
-	if err != nil {
+	if _, err := tx.Exec(updateQuery, hashVersion, profile, songID, songID); err != nil {
 		return fmt.Errorf("error updating song fingerprinted status: %w", err)
 	}
 
+	// A song already marked is already counted in the stats
+	if !fingerprinted {
+		statsQuery := fmt.Sprintf(`
+			INSERT INTO %s (%s, %s)
+			SELECT DISTINCT %s, 1 FROM %s WHERE %s = ?
+			ON DUPLICATE KEY UPDATE %s = %s + 1`,
+			m.cfg.Tables.HashStats.Name,
+			m.cfg.Tables.HashStats.Fields.Hash,
+			m.cfg.Tables.HashStats.Fields.SongCount,
+			m.cfg.Tables.Fingerprints.Fields.Hash,
+			m.cfg.Tables.Fingerprints.Name,
+			m.cfg.Tables.Songs.Fields.ID,
+			m.cfg.Tables.HashStats.Fields.SongCount,
+			m.cfg.Tables.HashStats.Fields.SongCount)
+
+		if _, err := tx.Exec(statsQuery, songID); err != nil {
+			return fmt.Errorf("error updating hash stats: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing song fingerprinted status: %w", err)
+	}
+
 	return nil
 }
 
 // ListSongs returns all songs from the database
-func (m *DB) ListSongs() ([]Song, error) {
-	query := fmt.Sprintf("SELECT %s, %s, artist, %s, HEX(%s), %s, date_created FROM %s",
+func (m *DB) ListSongs() ([]database.Song, error) {
+	query := fmt.Sprintf("SELECT %s, %s, artist, %s, HEX(%s), %s, %s, %s, %s FROM %s",
 		m.cfg.Tables.Songs.Fields.ID,
 		m.cfg.Tables.Songs.Fields.Name,
 		m.cfg.Tables.Songs.Fields.Fingerprinted,
 		m.cfg.Tables.Songs.Fields.FileSHA1,
 		m.cfg.Tables.Songs.Fields.TotalHashes,
+		m.cfg.Tables.Songs.Fields.HashVersion,
+		m.cfg.Tables.Songs.Fields.Profile,
+		m.cfg.Tables.Songs.Fields.DateCreated,
 		m.cfg.Tables.Songs.Name)
 
 	rows, err := m.conn.Query(query)
@@ -262,10 +675,10 @@ func (m *DB) ListSongs() ([]Song, error) {
 	}
 	defer rows.Close()
 
-	var songs []Song
+	var songs []database.Song
 	for rows.Next() {
-		var s Song
-		if err := rows.Scan(&s.ID, &s.Name, &s.Artist, &s.Fingerprinted, &s.FileSHA1, &s.TotalHashes, &s.DateCreated); err != nil {
+		var s database.Song
+		if err := rows.Scan(&s.ID, &s.Name, &s.Artist, &s.Fingerprinted, &s.FileSHA1, &s.TotalHashes, &s.HashVersion, &s.Profile, &s.DateCreated); err != nil {
 			return nil, fmt.Errorf("error scanning song row: %w", err)
 		}
 		songs = append(songs, s)
@@ -274,6 +687,312 @@ func (m *DB) ListSongs() ([]Song, error) {
 	return songs, nil
 }
 
+// GetSongByID returns a single song from the database
+func (m *DB) GetSongByID(songID int) (database.Song, error) {
+	query := fmt.Sprintf("SELECT %s, %s, %s, %s, HEX(%s), %s, %s, %s, %s FROM %s WHERE %s = ?",
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.Name,
+		m.cfg.Tables.Songs.Fields.Artist,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Fields.FileSHA1,
+		m.cfg.Tables.Songs.Fields.TotalHashes,
+		m.cfg.Tables.Songs.Fields.HashVersion,
+		m.cfg.Tables.Songs.Fields.Profile,
+		m.cfg.Tables.Songs.Fields.DateCreated,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	var s database.Song
+	err := m.conn.QueryRow(query, songID).Scan(&s.ID, &s.Name, &s.Artist, &s.Fingerprinted, &s.FileSHA1, &s.TotalHashes, &s.HashVersion, &s.Profile, &s.DateCreated)
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("song with ID %d not found", songID)
+	}
+	if err != nil {
+		return s, fmt.Errorf("error querying song: %w", err)
+	}
+
+	return s, nil
+}
+
+// GetSongsByIDs returns the songs with the given IDs, keyed by ID. IDs
+// without a song are left out of the map.
+func (m *DB) GetSongsByIDs(ctx context.Context, songIDs []int) (map[int]database.Song, error) {
+	songs := make(map[int]database.Song, len(songIDs))
+	if len(songIDs) == 0 {
+		return songs, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s, %s, %s, %s, HEX(%s), %s, %s, %s, %s FROM %s WHERE %s IN (%s)",
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.Name,
+		m.cfg.Tables.Songs.Fields.Artist,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Fields.FileSHA1,
+		m.cfg.Tables.Songs.Fields.TotalHashes,
+		m.cfg.Tables.Songs.Fields.HashVersion,
+		m.cfg.Tables.Songs.Fields.Profile,
+		m.cfg.Tables.Songs.Fields.DateCreated,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		placeholders(len(songIDs)))
+
+	args := make([]interface{}, len(songIDs))
+	for i, id := range songIDs {
+		args[i] = id
+	}
+
+	rows, err := m.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying songs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s database.Song
+		if err := rows.Scan(&s.ID, &s.Name, &s.Artist, &s.Fingerprinted, &s.FileSHA1, &s.TotalHashes, &s.HashVersion, &s.Profile, &s.DateCreated); err != nil {
+			return nil, fmt.Errorf("error scanning song row: %w", err)
+		}
+		songs[s.ID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating song rows: %w", err)
+	}
+
+	return songs, nil
+}
+
+// ReturnMatches looks up the given hashes in the fingerprints table and returns
+// every stored fingerprint that shares one of them and belongs to a song
+// passing the filter. Hashes are queried in batches to keep the IN clause at a
+// reasonable size.
+func (m *DB) ReturnMatches(ctx context.Context, hashes []string, filter database.Filter) ([]fingerprint.Fingerprint, error) {
+	var matches []fingerprint.Fingerprint
+	filterSQL, filterArgs := m.filterClause(filter)
+
+	for start := 0; start < len(hashes); start += matchBatchSize {
+		end := start + matchBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		batch := hashes[start:end]
+
+		hashPlaceholders := strings.TrimSuffix(strings.Repeat("UNHEX(?), ", len(batch)), ", ")
+		query := fmt.Sprintf("SELECT LOWER(HEX(%s)), %s, %s FROM %s WHERE %s IN (%s)%s",
+			m.cfg.Tables.Fingerprints.Fields.Hash,
+			m.cfg.Tables.Songs.Fields.ID,
+			m.cfg.Tables.Fingerprints.Fields.Offset,
+			m.cfg.Tables.Fingerprints.Name,
+			m.cfg.Tables.Fingerprints.Fields.Hash,
+			hashPlaceholders,
+			filterSQL)
+
+		args := make([]interface{}, len(batch), len(batch)+len(filterArgs))
+		for i, hash := range batch {
+			args[i] = hash
+		}
+		args = append(args, filterArgs...)
+
+		rows, err := m.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("error querying fingerprints: %w", err)
+		}
+
+		for rows.Next() {
+			var fp fingerprint.Fingerprint
+			if err := rows.Scan(&fp.Hash, &fp.SongID, &fp.Offset); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("error scanning fingerprint row: %w", err)
+			}
+			matches = append(matches, fp)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error iterating fingerprint rows: %w", err)
+		}
+		rows.Close()
+	}
+
+	return matches, nil
+}
+
+// AddSongTags attaches tags to a song, tags it already has are kept once
+func (m *DB) AddSongTags(songID int, tags []string) error {
+	query := fmt.Sprintf("INSERT IGNORE INTO %s (%s, %s) VALUES (?, ?)",
+		m.cfg.Tables.SongTags.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongTags.Fields.Tag)
+
+	for _, tag := range tags {
+		if _, err := m.conn.Exec(query, songID, tag); err != nil {
+			return fmt.Errorf("error tagging song: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetSongTags returns the tags of a song in alphabetical order
+func (m *DB) GetSongTags(songID int) ([]string, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ? ORDER BY %s",
+		m.cfg.Tables.SongTags.Fields.Tag,
+		m.cfg.Tables.SongTags.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongTags.Fields.Tag)
+
+	rows, err := m.conn.Query(query, songID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying song tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("error scanning song tag row: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// SetSongMarkers replaces the markers of a song
+func (m *DB) SetSongMarkers(songID int, markers []database.Marker) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE %s = ?",
+		m.cfg.Tables.SongMarkers.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	if _, err := tx.Exec(deleteQuery, songID); err != nil {
+		return fmt.Errorf("error deleting song markers: %w", err)
+	}
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (?, ?, ?)",
+		m.cfg.Tables.SongMarkers.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongMarkers.Fields.Name,
+		m.cfg.Tables.SongMarkers.Fields.Start)
+
+	for _, marker := range markers {
+		if _, err := tx.Exec(insertQuery, songID, marker.Name, marker.Start); err != nil {
+			return fmt.Errorf("error inserting song marker: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing song markers: %w", err)
+	}
+
+	return nil
+}
+
+// GetSongMarkers returns the markers of a song ordered by start
+func (m *DB) GetSongMarkers(songID int) ([]database.Marker, error) {
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = ? ORDER BY %s",
+		m.cfg.Tables.SongMarkers.Fields.Name,
+		m.cfg.Tables.SongMarkers.Fields.Start,
+		m.cfg.Tables.SongMarkers.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongMarkers.Fields.Start)
+
+	rows, err := m.conn.Query(query, songID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying song markers: %w", err)
+	}
+	defer rows.Close()
+
+	var markers []database.Marker
+	for rows.Next() {
+		var marker database.Marker
+		if err := rows.Scan(&marker.Name, &marker.Start); err != nil {
+			return nil, fmt.Errorf("error scanning song marker row: %w", err)
+		}
+		markers = append(markers, marker)
+	}
+
+	return markers, rows.Err()
+}
+
+// GetSongsMarkers returns the markers of several songs in a single query,
+// keyed by song ID and ordered by start. Songs without markers are absent.
+func (m *DB) GetSongsMarkers(ctx context.Context, songIDs []int) (map[int][]database.Marker, error) {
+	markers := make(map[int][]database.Marker, len(songIDs))
+	if len(songIDs) == 0 {
+		return markers, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s, %s, %s FROM %s WHERE %s IN (%s) ORDER BY %s, %s",
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongMarkers.Fields.Name,
+		m.cfg.Tables.SongMarkers.Fields.Start,
+		m.cfg.Tables.SongMarkers.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		placeholders(len(songIDs)),
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.SongMarkers.Fields.Start)
+
+	args := make([]interface{}, len(songIDs))
+	for i, id := range songIDs {
+		args[i] = id
+	}
+
+	rows, err := m.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying song markers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var songID int
+		var marker database.Marker
+		if err := rows.Scan(&songID, &marker.Name, &marker.Start); err != nil {
+			return nil, fmt.Errorf("error scanning song marker row: %w", err)
+		}
+		markers[songID] = append(markers[songID], marker)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating song marker rows: %w", err)
+	}
+
+	return markers, nil
+}
+
+// GetSongFingerprints returns every fingerprint stored for a song
+func (m *DB) GetSongFingerprints(songID int) ([]fingerprint.Fingerprint, error) {
+	query := fmt.Sprintf("SELECT LOWER(HEX(%s)), %s, %s FROM %s WHERE %s = ?",
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Fingerprints.Fields.Offset,
+		m.cfg.Tables.Fingerprints.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	rows, err := m.conn.Query(query, songID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying song fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	var fingerprints []fingerprint.Fingerprint
+	for rows.Next() {
+		var fp fingerprint.Fingerprint
+		if err := rows.Scan(&fp.Hash, &fp.SongID, &fp.Offset); err != nil {
+			return nil, fmt.Errorf("error scanning fingerprint row: %w", err)
+		}
+		fingerprints = append(fingerprints, fp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fingerprint rows: %w", err)
+	}
+
+	return fingerprints, nil
+}
+
 // Cleanup performs general database cleanup:
 // 1. Removes duplicate songs keeping only the fingerprinted ones
 // 2. Removes unfingerprinted songs
@@ -336,18 +1055,36 @@ func (m *DB) Cleanup() error {
 		logger.Info(fmt.Sprintf("Cleaned up %d orphaned fingerprints", rows))
 	}
 
+	// Deleted songs are not reflected in the hash stats
+	if err := m.RebuildHashStats(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// DeleteSong deletes a song and its fingerprints from the database
+// DeleteSong deletes a song and its fingerprints from the database, updating
+// the hash stats in the same transaction
 func (m *DB) DeleteSong(songID int) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Remove the song from the stats of its hashes while its fingerprints
+	// are still there to tell which hashes it holds
+	if err := m.decrementHashStats(tx, songID); err != nil {
+		return err
+	}
+
 	// Since we have ON DELETE CASCADE, we only need to delete the song
 	// and the fingerprints will be automatically deleted
 	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?",
 		m.cfg.Tables.Songs.Name,
 		m.cfg.Tables.Songs.Fields.ID)
 
-	result, err := m.conn.Exec(query, songID)
+	result, err := tx.Exec(query, songID)
 	if err != nil {
 		return fmt.Errorf("error deleting song: %w", err)
 	}
@@ -361,6 +1098,83 @@ func (m *DB) DeleteSong(songID int) error {
 		return fmt.Errorf("song with ID %d not found", songID)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing song deletion: %w", err)
+	}
+
 	logger.Info(fmt.Sprintf("Successfully deleted song with ID %d", songID))
 	return nil
 }
+
+// DeleteSongFingerprints deletes the fingerprints of a song and marks it as
+// not fingerprinted, keeping the song itself so that it can be fingerprinted
+// again under the same ID. The hash stats are updated in the same transaction.
+func (m *DB) DeleteSongFingerprints(songID int) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.decrementHashStats(tx, songID); err != nil {
+		return err
+	}
+
+	updateQuery := fmt.Sprintf("UPDATE %s SET %s = 0 WHERE %s = ?",
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	if _, err := tx.Exec(updateQuery, songID); err != nil {
+		return fmt.Errorf("error updating song fingerprinted status: %w", err)
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE %s = ?",
+		m.cfg.Tables.Fingerprints.Name,
+		m.cfg.Tables.Songs.Fields.ID)
+
+	if _, err := tx.Exec(deleteQuery, songID); err != nil {
+		return fmt.Errorf("error deleting song fingerprints: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing song fingerprints deletion: %w", err)
+	}
+
+	return nil
+}
+
+// decrementHashStats removes a song from the stats of every hash it holds.
+// Songs are only counted once marked as fingerprinted, so the fingerprints of
+// a song whose insert was interrupted are left out. It runs in the caller's
+// transaction so the stats change together with the deletion.
+func (m *DB) decrementHashStats(tx *sql.Tx, songID int) error {
+	statsQuery := fmt.Sprintf(`
+		UPDATE %s hs
+		INNER JOIN (
+			SELECT DISTINCT f.%s FROM %s f
+			INNER JOIN %s s ON s.%s = f.%s
+			WHERE f.%s = ? AND s.%s = 1
+		) fp ON hs.%s = fp.%s
+		SET hs.%s = hs.%s - 1
+		WHERE hs.%s > 0`,
+		m.cfg.Tables.HashStats.Name,
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.Fingerprints.Name,
+		m.cfg.Tables.Songs.Name,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.ID,
+		m.cfg.Tables.Songs.Fields.Fingerprinted,
+		m.cfg.Tables.HashStats.Fields.Hash,
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.HashStats.Fields.SongCount,
+		m.cfg.Tables.HashStats.Fields.SongCount,
+		m.cfg.Tables.HashStats.Fields.SongCount)
+
+	if _, err := tx.Exec(statsQuery, songID); err != nil {
+		return fmt.Errorf("error updating hash stats: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/media-luna/eureka/internal/database"
+)
+
+// filterClause builds the conditions restricting the song ID column of the
+// fingerprints or songs table to the songs passing the filter.
+//
+// Parameters:
+//   - filter: The filter to translate.
+//
+// Returns:
+//   - The conditions, each starting with AND, empty when the filter is empty.
+//   - The arguments of the placeholders in the conditions.
+func (m *DB) filterClause(filter database.Filter) (string, []interface{}) {
+	var clause strings.Builder
+	var args []interface{}
+	songID := m.cfg.Tables.Songs.Fields.ID
+
+	if len(filter.SongIDs) > 0 {
+		fmt.Fprintf(&clause, " AND %s IN (%s)", songID, placeholders(len(filter.SongIDs)))
+		for _, id := range filter.SongIDs {
+			args = append(args, id)
+		}
+	}
+	if len(filter.ExcludeSongIDs) > 0 {
+		fmt.Fprintf(&clause, " AND %s NOT IN (%s)", songID, placeholders(len(filter.ExcludeSongIDs)))
+		for _, id := range filter.ExcludeSongIDs {
+			args = append(args, id)
+		}
+	}
+
+	// Conditions on the songs table
+	var songConditions []string
+	if len(filter.Artists) > 0 {
+		songConditions = append(songConditions, fmt.Sprintf("%s IN (%s)", m.cfg.Tables.Songs.Fields.Artist, placeholders(len(filter.Artists))))
+		for _, artist := range filter.Artists {
+			args = append(args, artist)
+		}
+	}
+	if len(filter.ExcludeArtists) > 0 {
+		songConditions = append(songConditions, fmt.Sprintf("%s NOT IN (%s)", m.cfg.Tables.Songs.Fields.Artist, placeholders(len(filter.ExcludeArtists))))
+		for _, artist := range filter.ExcludeArtists {
+			args = append(args, artist)
+		}
+	}
+	if !filter.AddedAfter.IsZero() {
+		songConditions = append(songConditions, fmt.Sprintf("%s >= ?", m.cfg.Tables.Songs.Fields.DateCreated))
+		args = append(args, filter.AddedAfter)
+	}
+	if !filter.AddedBefore.IsZero() {
+		songConditions = append(songConditions, fmt.Sprintf("%s < ?", m.cfg.Tables.Songs.Fields.DateCreated))
+		args = append(args, filter.AddedBefore)
+	}
+	if filter.Profile != "" {
+		songConditions = append(songConditions, fmt.Sprintf("%s = ?", m.cfg.Tables.Songs.Fields.Profile))
+		args = append(args, filter.Profile)
+	}
+	if len(songConditions) > 0 {
+		fmt.Fprintf(&clause, " AND %s IN (SELECT %s FROM %s WHERE %s)",
+			songID, songID, m.cfg.Tables.Songs.Name, strings.Join(songConditions, " AND "))
+	}
+
+	// Conditions on the tags table
+	if len(filter.Tags) > 0 {
+		fmt.Fprintf(&clause, " AND %s IN (SELECT %s FROM %s WHERE %s IN (%s))",
+			songID, songID, m.cfg.Tables.SongTags.Name, m.cfg.Tables.SongTags.Fields.Tag, placeholders(len(filter.Tags)))
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+	}
+	if len(filter.ExcludeTags) > 0 {
+		fmt.Fprintf(&clause, " AND %s NOT IN (SELECT %s FROM %s WHERE %s IN (%s))",
+			songID, songID, m.cfg.Tables.SongTags.Name, m.cfg.Tables.SongTags.Fields.Tag, placeholders(len(filter.ExcludeTags)))
+		for _, tag := range filter.ExcludeTags {
+			args = append(args, tag)
+		}
+	}
+
+	return clause.String(), args
+}
+
+// placeholders returns n comma separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
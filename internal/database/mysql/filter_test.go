@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	config "github.com/media-luna/eureka/configs"
+	"github.com/media-luna/eureka/internal/database"
+)
+
+func TestFilterClause(t *testing.T) {
+	var cfg config.Config
+	cfg.Tables.Songs.Name = "songs"
+	cfg.Tables.Songs.Fields.ID = "song_id"
+	cfg.Tables.Songs.Fields.Artist = "artist"
+	cfg.Tables.Songs.Fields.Profile = "profile"
+	cfg.Tables.Songs.Fields.DateCreated = "date_created"
+	cfg.Tables.SongTags.Name = "song_tags"
+	cfg.Tables.SongTags.Fields.Tag = "tag"
+	m := &DB{cfg: cfg}
+
+	added := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		filter   database.Filter
+		want     string
+		wantArgs []interface{}
+	}{
+		{name: "empty", filter: database.Filter{}},
+		{
+			name:     "song IDs",
+			filter:   database.Filter{SongIDs: []int{1, 2}, ExcludeSongIDs: []int{3}},
+			want:     " AND song_id IN (?, ?) AND song_id NOT IN (?)",
+			wantArgs: []interface{}{1, 2, 3},
+		},
+		{
+			name:     "artists",
+			filter:   database.Filter{Artists: []string{"a"}, ExcludeArtists: []string{"b", "c"}},
+			want:     " AND song_id IN (SELECT song_id FROM songs WHERE artist IN (?) AND artist NOT IN (?, ?))",
+			wantArgs: []interface{}{"a", "b", "c"},
+		},
+		{
+			name:     "added range",
+			filter:   database.Filter{AddedAfter: added, AddedBefore: added.AddDate(0, 1, 0)},
+			want:     " AND song_id IN (SELECT song_id FROM songs WHERE date_created >= ? AND date_created < ?)",
+			wantArgs: []interface{}{added, added.AddDate(0, 1, 0)},
+		},
+		{
+			name:     "profile shares the songs condition",
+			filter:   database.Filter{Artists: []string{"a"}, Profile: "short"},
+			want:     " AND song_id IN (SELECT song_id FROM songs WHERE artist IN (?) AND profile = ?)",
+			wantArgs: []interface{}{"a", "short"},
+		},
+		{
+			name:     "tags",
+			filter:   database.Filter{Tags: []string{"jingle"}, ExcludeTags: []string{"ad"}},
+			want:     " AND song_id IN (SELECT song_id FROM song_tags WHERE tag IN (?)) AND song_id NOT IN (SELECT song_id FROM song_tags WHERE tag IN (?))",
+			wantArgs: []interface{}{"jingle", "ad"},
+		},
+		{
+			name:     "arguments follow the conditions",
+			filter:   database.Filter{SongIDs: []int{1}, Artists: []string{"a"}, Tags: []string{"jingle"}},
+			want:     " AND song_id IN (?) AND song_id IN (SELECT song_id FROM songs WHERE artist IN (?)) AND song_id IN (SELECT song_id FROM song_tags WHERE tag IN (?))",
+			wantArgs: []interface{}{1, "a", "jingle"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, args := m.filterClause(tt.filter)
+			if clause != tt.want {
+				t.Errorf("got clause %q, want %q", clause, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got args %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
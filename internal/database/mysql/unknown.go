@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/media-luna/eureka/internal/database"
+	"github.com/media-luna/eureka/internal/fingerprint"
+)
+
+// InsertUnknownQuery stores an unknown query with its fingerprints and
+// returns the ID it was given
+func (m *DB) InsertUnknownQuery(query database.UnknownQuery) (int, error) {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queryInsert := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?)",
+		m.cfg.Tables.UnknownQueries.Name,
+		m.cfg.Tables.UnknownQueries.Fields.Source,
+		m.cfg.Tables.UnknownQueries.Fields.Position,
+		m.cfg.Tables.UnknownQueries.Fields.HashVersion,
+		m.cfg.Tables.UnknownQueries.Fields.Profile,
+		m.cfg.Tables.UnknownQueries.Fields.RecordedAt)
+
+	result, err := tx.Exec(queryInsert, query.Source, query.Position, query.HashVersion, query.Profile, query.RecordedAt)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting unknown query: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting unknown query ID: %w", err)
+	}
+
+	fpInsert := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (?, UNHEX(?), ?)",
+		m.cfg.Tables.UnknownFingerprints.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.Fingerprints.Fields.Offset)
+
+	stmt, err := tx.Prepare(fpInsert)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing unknown fingerprint insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, fp := range query.Fingerprints {
+		if _, err := stmt.Exec(id, fp.Hash, fp.Offset); err != nil {
+			return 0, fmt.Errorf("error inserting unknown fingerprint: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing unknown query: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// GetUnknownQueries returns the unknown queries fingerprinted with the given
+// hash version and profile, with their fingerprints, in the order they were
+// recorded
+func (m *DB) GetUnknownQueries(hashVersion int, profile string) ([]database.UnknownQuery, error) {
+	queriesQuery := fmt.Sprintf("SELECT %s, %s, %s, %s, %s, %s FROM %s WHERE %s = ? AND %s = ? ORDER BY %s, %s",
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Fields.Source,
+		m.cfg.Tables.UnknownQueries.Fields.Position,
+		m.cfg.Tables.UnknownQueries.Fields.HashVersion,
+		m.cfg.Tables.UnknownQueries.Fields.Profile,
+		m.cfg.Tables.UnknownQueries.Fields.RecordedAt,
+		m.cfg.Tables.UnknownQueries.Name,
+		m.cfg.Tables.UnknownQueries.Fields.HashVersion,
+		m.cfg.Tables.UnknownQueries.Fields.Profile,
+		m.cfg.Tables.UnknownQueries.Fields.RecordedAt,
+		m.cfg.Tables.UnknownQueries.Fields.ID)
+
+	rows, err := m.conn.Query(queriesQuery, hashVersion, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error querying unknown queries: %w", err)
+	}
+	defer rows.Close()
+
+	var queries []database.UnknownQuery
+	index := make(map[int]int)
+	for rows.Next() {
+		var q database.UnknownQuery
+		if err := rows.Scan(&q.ID, &q.Source, &q.Position, &q.HashVersion, &q.Profile, &q.RecordedAt); err != nil {
+			return nil, fmt.Errorf("error scanning unknown query row: %w", err)
+		}
+		index[q.ID] = len(queries)
+		queries = append(queries, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unknown query rows: %w", err)
+	}
+
+	fpQuery := fmt.Sprintf(`
+		SELECT f.%s, LOWER(HEX(f.%s)), f.%s FROM %s f
+		INNER JOIN %s q ON q.%s = f.%s
+		WHERE q.%s = ? AND q.%s = ?`,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.Fingerprints.Fields.Hash,
+		m.cfg.Tables.Fingerprints.Fields.Offset,
+		m.cfg.Tables.UnknownFingerprints.Name,
+		m.cfg.Tables.UnknownQueries.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		m.cfg.Tables.UnknownQueries.Fields.HashVersion,
+		m.cfg.Tables.UnknownQueries.Fields.Profile)
+
+	fpRows, err := m.conn.Query(fpQuery, hashVersion, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error querying unknown fingerprints: %w", err)
+	}
+	defer fpRows.Close()
+
+	for fpRows.Next() {
+		var queryID int
+		var fp fingerprint.Fingerprint
+		if err := fpRows.Scan(&queryID, &fp.Hash, &fp.Offset); err != nil {
+			return nil, fmt.Errorf("error scanning unknown fingerprint row: %w", err)
+		}
+		if i, ok := index[queryID]; ok {
+			queries[i].Fingerprints = append(queries[i].Fingerprints, fp)
+		}
+	}
+	if err := fpRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unknown fingerprint rows: %w", err)
+	}
+
+	return queries, nil
+}
+
+// DeleteUnknownQueries deletes unknown queries, their fingerprints are
+// deleted with them
+func (m *DB) DeleteUnknownQueries(queryIDs []int) error {
+	if len(queryIDs) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)",
+		m.cfg.Tables.UnknownQueries.Name,
+		m.cfg.Tables.UnknownQueries.Fields.ID,
+		placeholders(len(queryIDs)))
+
+	args := make([]interface{}, len(queryIDs))
+	for i, id := range queryIDs {
+		args[i] = id
+	}
+
+	if _, err := m.conn.Exec(query, args...); err != nil {
+		return fmt.Errorf("error deleting unknown queries: %w", err)
+	}
+
+	return nil
+}
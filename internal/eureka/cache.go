@@ -0,0 +1,159 @@
+package eureka
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+)
+
+// resultCache is a bounded least recently used cache of recognition results,
+// keyed by a digest of the query fingerprints. Identical clips produce
+// identical fingerprints, so repeated queries skip the database lookup. A nil
+// cache never hits, which disables caching.
+//
+// Every clear starts a new generation. A query reads the generation before
+// its lookup and hands it back to put, so results computed from a catalogue
+// that changed while the query was running are never cached.
+type resultCache struct {
+	mu         sync.Mutex
+	capacity   int
+	entries    map[string]*list.Element
+	order      *list.List // Most recently used entry first
+	generation uint64
+}
+
+// cacheEntry is a cached result list with its key
+type cacheEntry struct {
+	key     string
+	results []Result
+}
+
+// newResultCache creates a cache holding at most capacity results, or returns
+// nil when capacity is not positive
+func newResultCache(capacity int) *resultCache {
+	if capacity <= 0 {
+		return nil
+	}
+
+	return &resultCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns a copy of the results cached for key
+func (c *resultCache) get(key string) ([]Result, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+
+	return append([]Result(nil), element.Value.(*cacheEntry).results...), true
+}
+
+// current returns the generation a query must pass to put, read before the
+// query looks anything up
+func (c *resultCache) current() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// put caches a copy of results for key, evicting the least recently used
+// entry when the cache is full. Results of an older generation are dropped.
+func (c *resultCache) put(key string, generation uint64, results []Result) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	results = append([]Result(nil), results...)
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).results = results
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, results: results})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// clear drops every cached result and starts a new generation, called
+// whenever the catalogue changes
+func (c *resultCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// queryKey digests query fingerprints, together with the filter and every
+// setting that changes the results of a lookup, into a cache key. The
+// fingerprints are sorted first so the key does not depend on the order they
+// were generated in.
+//
+// Parameters:
+//   - fingerprints: The fingerprints looked up for the query.
+//   - queryHashes: The number of hashes confidences are measured against.
+//
+// Returns:
+//   - The hex encoded digest.
+func (e *Eureka) queryKey(fingerprints []fingerprint.Fingerprint, queryHashes int) string {
+	entries := make([]string, len(fingerprints))
+	for i, fp := range fingerprints {
+		entries[i] = fmt.Sprintf("%s:%d", fp.Hash, fp.Offset)
+	}
+	sort.Strings(entries)
+
+	h := sha1.New()
+	recognition := e.Config.Recognition
+	fmt.Fprintf(h, "%d\n%v\n%s\n", queryHashes, e.filter, e.Config.Config.Profile)
+	fmt.Fprintf(h, "top=%d idf=%t,%g jitter=%d,%d\n",
+		recognition.TopResults,
+		recognition.IDFWeighting, recognition.IDFMaxSongRatio,
+		recognition.JitterFreqBins, recognition.JitterTimeFrames)
+	fmt.Fprintf(h, "tempo=%t,%g,%g pitch=%t,%g,%g\n",
+		recognition.TempoSearch, recognition.TempoMaxChange, recognition.TempoStep,
+		recognition.PitchSearch, recognition.PitchMaxSemitones, recognition.PitchStepSemitones)
+	for _, entry := range entries {
+		h.Write([]byte(entry))
+		h.Write([]byte{'\n'})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
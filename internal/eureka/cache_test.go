@@ -0,0 +1,103 @@
+package eureka
+
+import "testing"
+
+func TestResultCache(t *testing.T) {
+	type step struct {
+		op      string // put, get, clear or stale, a put with the generation read before a clear
+		key     string
+		wantHit bool
+	}
+
+	tests := []struct {
+		name     string
+		capacity int
+		steps    []step
+	}{
+		{
+			name:     "hit after put",
+			capacity: 2,
+			steps:    []step{{op: "get", key: "a"}, {op: "put", key: "a"}, {op: "get", key: "a", wantHit: true}},
+		},
+		{
+			name:     "least recently used evicted",
+			capacity: 2,
+			steps: []step{
+				{op: "put", key: "a"},
+				{op: "put", key: "b"},
+				{op: "get", key: "a", wantHit: true},
+				{op: "put", key: "c"},
+				{op: "get", key: "b"},
+				{op: "get", key: "a", wantHit: true},
+				{op: "get", key: "c", wantHit: true},
+			},
+		},
+		{
+			name:     "put refreshes an entry",
+			capacity: 2,
+			steps: []step{
+				{op: "put", key: "a"},
+				{op: "put", key: "b"},
+				{op: "put", key: "a"},
+				{op: "put", key: "c"},
+				{op: "get", key: "a", wantHit: true},
+				{op: "get", key: "b"},
+			},
+		},
+		{
+			name:     "clear drops every entry",
+			capacity: 2,
+			steps:    []step{{op: "put", key: "a"}, {op: "clear"}, {op: "get", key: "a"}, {op: "put", key: "a"}, {op: "get", key: "a", wantHit: true}},
+		},
+		{
+			name:     "results of an older generation dropped",
+			capacity: 2,
+			steps:    []step{{op: "stale", key: "a"}, {op: "get", key: "a"}},
+		},
+		{
+			name:     "disabled",
+			capacity: 0,
+			steps:    []step{{op: "put", key: "a"}, {op: "get", key: "a"}, {op: "clear"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newResultCache(tt.capacity)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "put":
+					c.put(s.key, c.current(), []Result{{SongName: s.key}})
+				case "stale":
+					generation := c.current()
+					c.clear()
+					c.put(s.key, generation, []Result{{SongName: s.key}})
+				case "clear":
+					c.clear()
+				case "get":
+					results, hit := c.get(s.key)
+					if hit != s.wantHit {
+						t.Fatalf("step %d: get %s hit %t, want %t", i, s.key, hit, s.wantHit)
+					}
+					if hit && (len(results) != 1 || results[0].SongName != s.key) {
+						t.Fatalf("step %d: get %s returned %+v", i, s.key, results)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResultCacheReturnsCopies(t *testing.T) {
+	c := newResultCache(1)
+	results := []Result{{SongName: "a"}}
+	c.put("a", c.current(), results)
+	results[0].SongName = "changed"
+
+	cached, _ := c.get("a")
+	cached[0].SongName = "changed"
+
+	if again, _ := c.get("a"); again[0].SongName != "a" {
+		t.Errorf("cached result changed to %q", again[0].SongName)
+	}
+}
@@ -0,0 +1,73 @@
+package eureka
+
+import (
+	"fmt"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+)
+
+// Comparison represents how two recordings match each other
+type Comparison struct {
+	HashesA       int     // Number of hashes extracted from the first recording
+	HashesB       int     // Number of hashes extracted from the second recording
+	AlignedHashes int     // Number of hashes that agree on Offset
+	Offset        int     // Position of the start of the second recording within the first, in milliseconds
+	OffsetSeconds float64 // Offset expressed in seconds
+
+	// Similarity is the share of the shorter recording's hashes that aligned,
+	// close to 1 when both are the same recording and 0 when they are unrelated.
+	Similarity float64
+}
+
+// Compare fingerprints two audio files with the given profile and matches
+// them against each other in memory, without a database.
+//
+// Parameters:
+//   - pathA: The path to the first recording.
+//   - pathB: The path to the second recording.
+//   - profile: The peak picking and pairing settings of the fingerprints.
+//
+// Returns:
+//   - The comparison of the two recordings.
+//   - An error if either file could not be processed.
+func Compare(pathA string, pathB string, profile fingerprint.Profile) (*Comparison, error) {
+	fingerprintsA, err := FingerprintFile(pathA, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error fingerprinting %s: %v", pathA, err)
+	}
+
+	fingerprintsB, err := FingerprintFile(pathB, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error fingerprinting %s: %v", pathB, err)
+	}
+
+	return CompareFingerprints(fingerprintsA, fingerprintsB), nil
+}
+
+// CompareFingerprints matches the fingerprints of two recordings against each
+// other using the same offset alignment as recognition.
+func CompareFingerprints(fingerprintsA []fingerprint.Fingerprint, fingerprintsB []fingerprint.Fingerprint) *Comparison {
+	comparison := &Comparison{
+		HashesA: len(fingerprintsA),
+		HashesB: len(fingerprintsB),
+	}
+
+	// The first recording plays the part of a single stored song
+	alignments := alignMatches(fingerprintsB, fingerprintsA, nil)
+	if len(alignments) == 0 {
+		return comparison
+	}
+
+	best := alignments[0]
+	comparison.AlignedHashes = best.Count
+	comparison.Offset = best.Offset
+	comparison.OffsetSeconds = float64(best.Offset) / 1000
+
+	shorter := comparison.HashesA
+	if comparison.HashesB < shorter {
+		shorter = comparison.HashesB
+	}
+	comparison.Similarity = confidence(best.Count, shorter)
+
+	return comparison
+}
@@ -0,0 +1,67 @@
+package eureka
+
+import (
+	"testing"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+)
+
+func TestCompareFingerprints(t *testing.T) {
+	recording := []fingerprint.Fingerprint{
+		testFingerprint("a", 0, 0),
+		testFingerprint("b", 100, 0),
+		testFingerprint("c", 200, 0),
+		testFingerprint("d", 300, 0),
+	}
+
+	tests := []struct {
+		name string
+		a    []fingerprint.Fingerprint
+		b    []fingerprint.Fingerprint
+		want Comparison
+	}{
+		{
+			name: "same recording",
+			a:    recording,
+			b:    recording,
+			want: Comparison{HashesA: 4, HashesB: 4, AlignedHashes: 4, Similarity: 1},
+		},
+		{
+			name: "excerpt one second in",
+			a: []fingerprint.Fingerprint{
+				testFingerprint("x", 0, 0),
+				testFingerprint("a", 1000, 0),
+				testFingerprint("b", 1100, 0),
+				testFingerprint("c", 1200, 0),
+			},
+			b:    recording[:3],
+			want: Comparison{HashesA: 4, HashesB: 3, AlignedHashes: 3, Offset: 1000, OffsetSeconds: 1, Similarity: 1},
+		},
+		{
+			name: "partly shared",
+			a:    recording,
+			b:    []fingerprint.Fingerprint{testFingerprint("a", 0, 0), testFingerprint("b", 100, 0), testFingerprint("y", 200, 0), testFingerprint("z", 300, 0)},
+			want: Comparison{HashesA: 4, HashesB: 4, AlignedHashes: 2, Similarity: 0.5},
+		},
+		{
+			name: "unrelated",
+			a:    recording,
+			b:    []fingerprint.Fingerprint{testFingerprint("y", 0, 0), testFingerprint("z", 100, 0)},
+			want: Comparison{HashesA: 4, HashesB: 2},
+		},
+		{
+			name: "empty",
+			a:    recording,
+			want: Comparison{HashesA: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareFingerprints(tt.a, tt.b)
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
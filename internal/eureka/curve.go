@@ -0,0 +1,129 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	CURVE_CHUNK_SECONDS = 30 // Length of the chunks a long query is fingerprinted in
+)
+
+// ConfidenceCurve tells where in a query a candidate song was heard
+type ConfidenceCurve struct {
+	Result     Result    `json:"result"`
+	Hashes     []int     `json:"hashes"`     // Query hashes generated in each second of the query
+	Aligned    []int     `json:"aligned"`    // Query hashes of each second that agree on the offset of the result
+	Confidence []float64 `json:"confidence"` // Share of the hashes of each second that are aligned
+}
+
+// RecognizeCurve identifies an audio clip and measures, for every second of
+// the clip, the share of its hashes that agree with each of the top results.
+// A song playing throughout a long query keeps a steady curve, while speech,
+// noise or another song taking over shows up as a drop. The clip is
+// fingerprinted in chunks so that long queries keep their full resolution,
+// and it always runs a single plain query, so the tempo, pitch and voting
+// options do not apply.
+//
+// Parameters:
+//   - path: The path to the audio file to identify.
+//
+// Returns:
+//   - One curve per result, at most recognition.top_results of them.
+//   - An error if the file could not be processed or the lookup failed.
+func (e *Eureka) RecognizeCurve(path string) ([]ConfidenceCurve, error) {
+	samples, sampleRate, err := loadSamples(path)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := fingerprintChunked(samples, sampleRate, CURVE_CHUNK_SECONDS, e.fingerprintProfile())
+	if err != nil {
+		return nil, err
+	}
+	seconds := (len(samples) + sampleRate - 1) / sampleRate
+	logger.Info(fmt.Sprintf("Generated %d query fingerprints over %d seconds", len(query), seconds))
+
+	replies, _, err := queryCatalogues(e, func(ctx context.Context, _ int, c *Eureka) (catalogueLookup, error) {
+		matches, weights, err := c.lookup(ctx, uniqueHashes(query))
+		return catalogueLookup{matches: matches, weights: weights}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make([][]Result, len(replies))
+	names := make([]string, len(replies))
+	matches := make(map[string][]fingerprint.Fingerprint, len(replies))
+	for i, reply := range replies {
+		owner, err := e.catalogueNamed(reply.name)
+		if err != nil {
+			return nil, err
+		}
+		lists[i], err = owner.results(context.Background(), alignMatches(query, reply.value.matches, reply.value.weights), len(query))
+		if err != nil {
+			return nil, err
+		}
+		names[i] = reply.name
+		matches[reply.name] = reply.value.matches
+	}
+
+	results := e.mergeResults(lists, names)
+	curves := make([]ConfidenceCurve, len(results))
+	for i, result := range results {
+		curves[i] = confidenceCurve(query, matches[result.Catalogue], result, seconds)
+	}
+
+	return curves, nil
+}
+
+// confidenceCurve buckets the query hashes by second and counts those with a
+// stored fingerprint of the result's song within OFFSET_TOLERANCE_MS of its
+// offset.
+//
+// Parameters:
+//   - query: The fingerprints of the query.
+//   - matches: The stored fingerprints sharing a hash with the query.
+//   - result: The result the curve is computed for.
+//   - seconds: The length of the query, in whole seconds.
+//
+// Returns:
+//   - The curve of the result.
+func confidenceCurve(query []fingerprint.Fingerprint, matches []fingerprint.Fingerprint, result Result, seconds int) ConfidenceCurve {
+	stored := make(map[string][]int)
+	for _, m := range matches {
+		if m.SongID == result.SongID {
+			stored[m.Hash] = append(stored[m.Hash], m.Offset)
+		}
+	}
+
+	curve := ConfidenceCurve{
+		Result:     result,
+		Hashes:     make([]int, seconds),
+		Aligned:    make([]int, seconds),
+		Confidence: make([]float64, seconds),
+	}
+	for _, fp := range query {
+		second := fp.Offset / 1000
+		if second >= seconds {
+			second = seconds - 1
+		}
+		curve.Hashes[second]++
+
+		for _, offset := range stored[fp.Hash] {
+			if diff := offset - fp.Offset - result.Offset; diff >= -OFFSET_TOLERANCE_MS && diff <= OFFSET_TOLERANCE_MS {
+				curve.Aligned[second]++
+				break
+			}
+		}
+	}
+
+	for second := range curve.Confidence {
+		curve.Confidence[second] = confidence(curve.Aligned[second], curve.Hashes[second])
+	}
+
+	return curve
+}
@@ -0,0 +1,74 @@
+package eureka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/media-luna/eureka/internal/fingerprint"
+)
+
+func TestConfidenceCurve(t *testing.T) {
+	// Hashes a, b and c fall in the first second, d in the second and e in the last
+	query := []fingerprint.Fingerprint{
+		testFingerprint("a", 0, 0),
+		testFingerprint("b", 400, 0),
+		testFingerprint("c", 900, 0),
+		testFingerprint("d", 1500, 0),
+		testFingerprint("e", 2999, 0),
+	}
+	result := Result{SongID: 1, Offset: 10000}
+
+	tests := []struct {
+		name        string
+		matches     []fingerprint.Fingerprint
+		seconds     int
+		wantHashes  []int
+		wantAligned []int
+	}{
+		{
+			name:        "aligned throughout",
+			matches:     []fingerprint.Fingerprint{testFingerprint("a", 10000, 1), testFingerprint("b", 10400, 1), testFingerprint("c", 10900, 1), testFingerprint("d", 11500, 1), testFingerprint("e", 12999, 1)},
+			seconds:     3,
+			wantHashes:  []int{3, 1, 1},
+			wantAligned: []int{3, 1, 1},
+		},
+		{
+			name:        "other songs ignored",
+			matches:     []fingerprint.Fingerprint{testFingerprint("a", 10000, 1), testFingerprint("d", 11500, 2)},
+			seconds:     3,
+			wantHashes:  []int{3, 1, 1},
+			wantAligned: []int{1, 0, 0},
+		},
+		{
+			name:        "offsets within tolerance",
+			matches:     []fingerprint.Fingerprint{testFingerprint("a", 10000+OFFSET_TOLERANCE_MS, 1), testFingerprint("b", 10400+OFFSET_TOLERANCE_MS+1, 1), testFingerprint("d", 500, 1), testFingerprint("d", 11500-OFFSET_TOLERANCE_MS, 1)},
+			seconds:     3,
+			wantHashes:  []int{3, 1, 1},
+			wantAligned: []int{1, 1, 0},
+		},
+		{
+			name:        "hashes past the end counted in the last second",
+			matches:     []fingerprint.Fingerprint{testFingerprint("e", 12999, 1)},
+			seconds:     2,
+			wantHashes:  []int{3, 2},
+			wantAligned: []int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			curve := confidenceCurve(query, tt.matches, result, tt.seconds)
+			if !reflect.DeepEqual(curve.Hashes, tt.wantHashes) {
+				t.Errorf("hashes per second %v, want %v", curve.Hashes, tt.wantHashes)
+			}
+			if !reflect.DeepEqual(curve.Aligned, tt.wantAligned) {
+				t.Errorf("aligned hashes per second %v, want %v", curve.Aligned, tt.wantAligned)
+			}
+			for second, c := range curve.Confidence {
+				if want := confidence(tt.wantAligned[second], tt.wantHashes[second]); c != want {
+					t.Errorf("confidence of second %d = %v, want %v", second, c, want)
+				}
+			}
+		})
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 	config "github.com/media-luna/eureka/configs"
 	"github.com/media-luna/eureka/internal/database"
-	"github.com/media-luna/eureka/internal/database/mysql"
 	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
 	"github.com/media-luna/eureka/utils/logger"
 	"github.com/schollz/progressbar/v3"
@@ -17,8 +16,17 @@ import (
 // Eureka represents the main structure for the Eureka service,
 // containing the configuration settings required for its operation.
 type Eureka struct {
-	Config   config.Config
-	database database.Database
+	Config     config.Config
+	database   database.Database
+	cache      *resultCache
+	catalogues []catalogue
+	filter     database.Filter // Restricts the songs considered by recognition, see WithFilter
+
+	mixedProfiles bool // Some songs were fingerprinted with another profile, see checkProfile
+
+	// OnCatalogueError, when set, is called for every catalogue that fails or
+	// times out during a federated recognition.
+	OnCatalogueError func(catalogue string, err error)
 }
 
 // NewEureka initializes a new Eureka instance with the provided configuration.
@@ -52,10 +60,32 @@ func NewEureka(config config.Config) (*Eureka, error) {
 		return nil, err
 	}
 
-	return &Eureka{
-		Config:   config,
-		database: db,
-	}, nil
+	// Open the catalogues searched by recognition, if any
+	catalogues, err := openCatalogues(config)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Eureka{
+		Config:     config,
+		database:   db,
+		cache:      newResultCache(config.Recognition.CacheSize),
+		catalogues: catalogues,
+	}
+	if err := e.SetProfile(config.Config.Profile); err != nil {
+		return nil, err
+	}
+
+	// Songs hashed with an older scheme never match, see Reingest
+	outdated, err := db.GetNumOutdatedSongs(fingerprint.HASH_VERSION)
+	if err != nil {
+		return nil, err
+	}
+	if outdated > 0 {
+		logger.Warn(fmt.Sprintf("%d songs were fingerprinted with an outdated hash version and will not be recognized, run -reingest on their audio files", outdated))
+	}
+
+	return e, nil
 }
 
 // Save processes an audio file, generates its spectrogram, and extracts fingerprints.
@@ -93,7 +123,7 @@ func (e *Eureka) Save(path string) error {
 	}
 
 	// Collect spectrogram peaks
-	peaks := fingerprint.PickPeaks(spectrogram, wavInfo.SampleRate)
+	peaks := fingerprint.PickPeaks(spectrogram, wavInfo.SampleRate, e.fingerprintProfile())
 	logger.Info(fmt.Sprintf("Found %d peaks in spectrogram", len(peaks)))
 
 	// Save spectrogram image with peaks
@@ -103,7 +133,7 @@ func (e *Eureka) Save(path string) error {
 
 	// Generate fingerprints
 	logger.Info("Generating fingerprints...")
-	fingerprints := fingerprint.GenerateFingerprints(peaks)
+	fingerprints := fingerprint.GenerateFingerprints(peaks, e.fingerprintProfile())
 	logger.Info(fmt.Sprintf("Generated %d fingerprints", len(fingerprints)))
 
 	// Calculate file hash
@@ -125,12 +155,98 @@ func (e *Eureka) Save(path string) error {
 		artistName = "" // Empty artist name
 	}
 
-	// Store song in database
+	return e.storeSong(songName, artistName, fileHash, fingerprints)
+}
+
+// Reingest fingerprints again every audio file of a directory, walked
+// recursively, whose song was stored with an outdated hash version or another
+// profile than the selected one. Songs at the current version and profile are
+// left untouched and files not in the database are
+// saved as new songs, so it is safe to run on a whole music library. A file
+// that fails is reported and does not stop the others.
+//
+// Parameters:
+//   - dir: The directory holding the original audio files.
+//
+// Returns:
+//   - An error if the directory could not be walked or any file failed.
+func (e *Eureka) Reingest(dir string) error {
+	var processed, failed int
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		processed++
+		if err := e.Save(path); err != nil {
+			logger.Error(fmt.Errorf("error re-ingesting %s: %v", path, err))
+			failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking directory: %v", err)
+	}
+
+	outdated, err := e.database.GetNumOutdatedSongs(fingerprint.HASH_VERSION)
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Re-ingested %d files, %d songs still have an outdated hash version", processed, outdated))
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be re-ingested", failed, processed)
+	}
+
+	return nil
+}
+
+// SaveSamples stores decoded mono samples as a song without going through the
+// filesystem. The samples hash takes the place of the file hash so the same
+// audio is not stored twice.
+func (e *Eureka) SaveSamples(samples []float64, sampleRate int, songName string, artistName string) error {
+	fingerprints, err := fingerprint.FingerprintSamples(samples, sampleRate, e.fingerprintProfile())
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Generated %d fingerprints", len(fingerprints)))
+
+	return e.storeSong(songName, artistName, fingerprint.CalculateSamplesHash(samples), fingerprints)
+}
+
+// storeSong inserts a song with its fingerprints and marks it as fingerprinted.
+// A song already fingerprinted with the current hash version and profile is
+// left as is, while one fingerprinted with an older version or another profile
+// has its fingerprints replaced.
+func (e *Eureka) storeSong(songName string, artistName string, fileHash string, fingerprints []fingerprint.Fingerprint) error {
 	songID, err := e.database.InsertSong(songName, artistName, fileHash, len(fingerprints))
 	if err != nil {
 		return fmt.Errorf("error inserting song: %v", err)
 	}
 
+	song, err := e.database.GetSongByID(songID)
+	if err != nil {
+		return fmt.Errorf("error loading song: %v", err)
+	}
+	if song.Fingerprinted && song.HashVersion >= fingerprint.HASH_VERSION && song.Profile == e.Config.Config.Profile {
+		logger.Info(fmt.Sprintf("%s is already fingerprinted", songName))
+		return nil
+	}
+	// Cached results may miss the new song. Clearing starts a new cache
+	// generation, so queries that ran while its fingerprints were stored
+	// cannot cache their results afterwards either.
+	defer e.cache.clear()
+
+	if song.Fingerprinted {
+		logger.Info(fmt.Sprintf("Replacing fingerprints of hash version %d and profile %s", song.HashVersion, song.Profile))
+		if err := e.database.DeleteSongFingerprints(songID); err != nil {
+			return fmt.Errorf("error deleting outdated fingerprints: %v", err)
+		}
+	}
+
 	// Store fingerprints with progress bar
 	logger.Info("Storing fingerprints in database...")
 	bar := progressbar.Default(int64(len(fingerprints)))
@@ -142,7 +258,7 @@ func (e *Eureka) Save(path string) error {
 	}
 
 	// Mark song as fingerprinted only after all fingerprints are stored
-	if err := e.database.UpdateSongFingerprinted(songID); err != nil {
+	if err := e.database.UpdateSongFingerprinted(songID, fingerprint.HASH_VERSION, e.Config.Config.Profile); err != nil {
 		return fmt.Errorf("error marking song as fingerprinted: %v", err)
 	}
 	logger.Info(fmt.Sprintf("Successfully processed %s", songName))
@@ -150,23 +266,50 @@ func (e *Eureka) Save(path string) error {
 	return nil
 }
 
-// List returns all songs from the database
-func (e *Eureka) List() ([]mysql.Song, error) {
-	if db, ok := e.database.(*mysql.DB); ok {
-		return db.ListSongs()
+// WithFilter returns a copy of e whose recognitions only consider the songs
+// passing filter. The copy shares the databases and the cache of e, so it is
+// cheap to create one for every query.
+func (e *Eureka) WithFilter(filter database.Filter) *Eureka {
+	filtered := *e
+	filtered.filter = filter
+
+	filtered.catalogues = make([]catalogue, len(e.catalogues))
+	for i, c := range e.catalogues {
+		child := *c.eureka
+		child.filter = filter
+		filtered.catalogues[i] = catalogue{name: c.name, eureka: &child}
 	}
-	return nil, fmt.Errorf("database type does not support listing songs")
+
+	return &filtered
+}
+
+// Tag attaches tags to a song, which recognition filters can select on
+func (e *Eureka) Tag(songID int, tags []string) error {
+	if _, err := e.database.GetSongByID(songID); err != nil {
+		return err
+	}
+	defer e.cache.clear()
+	return e.database.AddSongTags(songID, tags)
+}
+
+// Tags returns the tags of a song
+func (e *Eureka) Tags(songID int) ([]string, error) {
+	return e.database.GetSongTags(songID)
+}
+
+// List returns all songs from the database
+func (e *Eureka) List() ([]database.Song, error) {
+	return e.database.ListSongs()
 }
 
 // Cleanup performs general database cleanup operations
 func (e *Eureka) Cleanup() error {
-	if db, ok := e.database.(*mysql.DB); ok {
-		return db.Cleanup()
-	}
-	return fmt.Errorf("database type does not support cleanup")
+	defer e.cache.clear()
+	return e.database.Cleanup()
 }
 
 // Delete deletes a song and its fingerprints from the database
 func (e *Eureka) Delete(songID int) error {
+	defer e.cache.clear()
 	return e.database.DeleteSong(songID)
 }
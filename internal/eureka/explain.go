@@ -0,0 +1,217 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	EXPLAIN_MAX_CANDIDATES = 10 // Number of candidate songs reported by Explain
+	EXPLAIN_TOP_BINS       = 5  // Number of offset histogram bins reported per candidate
+)
+
+// Explanation details how a query went through the recognition pipeline, to
+// understand why a clip was misidentified
+type Explanation struct {
+	Peaks          int         `json:"peaks"`            // Spectrogram peaks found in the query
+	Hashes         int         `json:"hashes"`           // Hashes generated from the peaks, jitter expansions included
+	UniqueHashes   int         `json:"unique_hashes"`    // Distinct hashes looked up
+	HashesHit      int         `json:"hashes_hit"`       // Distinct hashes found in the database
+	DatabaseHits   int         `json:"database_hits"`    // Stored fingerprints returned by the lookup
+	Candidates     []Candidate `json:"candidates"`       // Songs sharing the most hashes with the query
+	Results        []Result    `json:"results"`          // The results recognition returns for the query
+	StageTimingsMs StageTiming `json:"stage_timings_ms"` // Time spent in each pipeline stage
+}
+
+// Candidate is a song that shares hashes with the query
+type Candidate struct {
+	Catalogue string      `json:"catalogue,omitempty"`
+	SongID    int         `json:"song_id"`
+	Hits      int         `json:"hits"`     // Pairs of query and stored fingerprints sharing a hash, at any offset
+	TopBins   []OffsetBin `json:"top_bins"` // Strongest offset differences, best first
+}
+
+// OffsetBin is a peak of the offset difference histogram of a candidate
+type OffsetBin struct {
+	Offset int     `json:"offset_ms"` // Position of the query start within the song
+	Count  int     `json:"count"`     // Hashes within OFFSET_TOLERANCE_MS of the offset
+	Score  float64 `json:"score"`     // Count weighted by rarity when IDF weighting is enabled
+}
+
+// StageTiming holds the duration of each recognition stage, in milliseconds
+type StageTiming struct {
+	Load      float64 `json:"load"`      // Decoding the audio
+	Peaks     float64 `json:"peaks"`     // Computing the spectrogram and picking peaks
+	Hashing   float64 `json:"hashing"`   // Pairing peaks into hashes
+	Lookup    float64 `json:"lookup"`    // Fetching the hashes from the database or catalogues
+	Alignment float64 `json:"alignment"` // Building the offset histograms and aligning the candidates
+	Results   float64 `json:"results"`   // Loading the songs and markers of the results
+	Total     float64 `json:"total"`
+}
+
+// catalogueLookup is the answer of a single catalogue to an explained query
+type catalogueLookup struct {
+	matches []fingerprint.Fingerprint
+	weights map[string]float64
+}
+
+// Explain recognizes an audio clip like Recognize and reports what each stage
+// of the pipeline produced. It always runs a single plain query, bypassing the
+// result cache, so the tempo, pitch and voting options are not explained.
+//
+// Parameters:
+//   - path: The path to the audio file to identify.
+//
+// Returns:
+//   - The explanation, including the results of the query.
+//   - An error if the file could not be processed or the lookup failed.
+func (e *Eureka) Explain(path string) (*Explanation, error) {
+	var explanation Explanation
+	timings := &explanation.StageTimingsMs
+	start := time.Now()
+	stage := start
+	elapsed := func() float64 {
+		now := time.Now()
+		ms := float64(now.Sub(stage)) / float64(time.Millisecond)
+		stage = now
+		return ms
+	}
+
+	samples, sampleRate, err := loadSamples(path)
+	if err != nil {
+		return nil, err
+	}
+	timings.Load = elapsed()
+
+	peaks, err := fingerprint.SamplesToPeaks(samples, sampleRate, e.fingerprintProfile())
+	if err != nil {
+		return nil, err
+	}
+	explanation.Peaks = len(peaks)
+	timings.Peaks = elapsed()
+
+	query := e.queryFingerprints(peaks)
+	hashes := uniqueHashes(query)
+	queryHashes := len(fingerprint.GenerateFingerprints(peaks, e.fingerprintProfile()))
+	explanation.Hashes = len(query)
+	explanation.UniqueHashes = len(hashes)
+	timings.Hashing = elapsed()
+
+	replies, _, err := queryCatalogues(e, func(ctx context.Context, _ int, c *Eureka) (catalogueLookup, error) {
+		matches, weights, err := c.lookup(ctx, hashes)
+		return catalogueLookup{matches: matches, weights: weights}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	timings.Lookup = elapsed()
+
+	// The candidates and the results come from the same histograms
+	hit := make(map[string]bool)
+	alignments := make([][]alignment, len(replies))
+	for i, reply := range replies {
+		explanation.DatabaseHits += len(reply.value.matches)
+		for _, m := range reply.value.matches {
+			hit[m.Hash] = true
+		}
+
+		histograms := offsetHistograms(query, reply.value.matches, reply.value.weights)
+		explanation.Candidates = append(explanation.Candidates, candidates(reply.name, histograms)...)
+		alignments[i] = alignHistograms(histograms)
+	}
+	explanation.HashesHit = len(hit)
+
+	sort.SliceStable(explanation.Candidates, func(i, j int) bool {
+		return explanation.Candidates[i].Hits > explanation.Candidates[j].Hits
+	})
+	if len(explanation.Candidates) > EXPLAIN_MAX_CANDIDATES {
+		explanation.Candidates = explanation.Candidates[:EXPLAIN_MAX_CANDIDATES]
+	}
+	timings.Alignment = elapsed()
+
+	lists := make([][]Result, len(replies))
+	names := make([]string, len(replies))
+	for i, reply := range replies {
+		owner, err := e.catalogueNamed(reply.name)
+		if err != nil {
+			return nil, err
+		}
+		lists[i], err = owner.results(context.Background(), alignments[i], queryHashes)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = reply.name
+	}
+	explanation.Results = e.mergeResults(lists, names)
+	timings.Results = elapsed()
+
+	timings.Total = float64(time.Since(start)) / float64(time.Millisecond)
+
+	logger.Info(fmt.Sprintf("Explained query with %d candidates", len(explanation.Candidates)))
+
+	return &explanation, nil
+}
+
+// candidates summarizes the offset histogram of every song of a catalogue
+func candidates(catalogue string, histograms map[int]offsetHistogram) []Candidate {
+	list := make([]Candidate, 0, len(histograms))
+	for songID, histogram := range histograms {
+		candidate := Candidate{Catalogue: catalogue, SongID: songID}
+		for _, b := range histogram {
+			candidate.Hits += b.count
+		}
+		candidate.TopBins = topBins(histogram)
+		list = append(list, candidate)
+	}
+
+	// Order ties by song ID so the report is stable
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Hits != list[j].Hits {
+			return list[i].Hits > list[j].Hits
+		}
+		return list[i].SongID < list[j].SongID
+	})
+
+	return list
+}
+
+// topBins returns the EXPLAIN_TOP_BINS strongest offset differences of a
+// histogram, counted like alignMatches does and leaving out differences
+// within OFFSET_TOLERANCE_MS of a stronger one.
+func topBins(histogram offsetHistogram) []OffsetBin {
+	bins := make([]OffsetBin, 0, len(histogram))
+	for diff := range histogram {
+		count, score := histogram.around(diff)
+		bins = append(bins, OffsetBin{Offset: diff, Count: count, Score: score})
+	}
+	sort.Slice(bins, func(i, j int) bool {
+		if bins[i].Score != bins[j].Score {
+			return bins[i].Score > bins[j].Score
+		}
+		return bins[i].Offset < bins[j].Offset
+	})
+
+	var top []OffsetBin
+	for _, b := range bins {
+		if len(top) == EXPLAIN_TOP_BINS {
+			break
+		}
+		near := false
+		for _, t := range top {
+			if b.Offset-t.Offset <= OFFSET_TOLERANCE_MS && t.Offset-b.Offset <= OFFSET_TOLERANCE_MS {
+				near = true
+				break
+			}
+		}
+		if !near {
+			top = append(top, b)
+		}
+	}
+
+	return top
+}
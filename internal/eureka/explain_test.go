@@ -0,0 +1,66 @@
+package eureka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopBins(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram offsetHistogram
+		want      []OffsetBin
+	}{
+		{name: "empty histogram"},
+		{
+			name: "neighbouring bins counted together",
+			histogram: offsetHistogram{
+				1000: {count: 3, score: 3},
+				1001: {count: 1, score: 1},
+				2000: {count: 2, score: 2},
+				5000: {count: 1, score: 1},
+			},
+			want: []OffsetBin{{Offset: 1000, Count: 4, Score: 4}, {Offset: 2000, Count: 2, Score: 2}, {Offset: 5000, Count: 1, Score: 1}},
+		},
+		{
+			name: "ordered by score",
+			histogram: offsetHistogram{
+				1000: {count: 5, score: 0.5},
+				2000: {count: 1, score: 3},
+			},
+			want: []OffsetBin{{Offset: 2000, Count: 1, Score: 3}, {Offset: 1000, Count: 5, Score: 0.5}},
+		},
+		{
+			name: "limited to the strongest bins",
+			histogram: offsetHistogram{
+				0:    {count: 1, score: 1},
+				100:  {count: 7, score: 7},
+				200:  {count: 2, score: 2},
+				300:  {count: 6, score: 6},
+				400:  {count: 3, score: 3},
+				500:  {count: 5, score: 5},
+				600:  {count: 4, score: 4},
+				1000: {count: 1, score: 1},
+			},
+			want: []OffsetBin{
+				{Offset: 100, Count: 7, Score: 7},
+				{Offset: 300, Count: 6, Score: 6},
+				{Offset: 500, Count: 5, Score: 5},
+				{Offset: 600, Count: 4, Score: 4},
+				{Offset: 400, Count: 3, Score: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := topBins(tt.histogram)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
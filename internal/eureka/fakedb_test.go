@@ -0,0 +1,249 @@
+package eureka
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/media-luna/eureka/internal/database"
+	"github.com/media-luna/eureka/internal/fingerprint"
+)
+
+const testSampleRate = 44100
+
+// fakeDB is an in-memory database.Database. Methods the tests do not need
+// are left to the embedded nil interface and panic when called.
+type fakeDB struct {
+	database.Database
+	songs        map[int]database.Song
+	fingerprints map[int][]fingerprint.Fingerprint
+	markers      map[int][]database.Marker
+	unknown      []database.UnknownQuery
+	err          error // Returned by every lookup when set
+	lookups      int   // Number of ReturnMatches calls
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{
+		songs:        make(map[int]database.Song),
+		fingerprints: make(map[int][]fingerprint.Fingerprint),
+		markers:      make(map[int][]database.Marker),
+	}
+}
+
+// addSong stores a song fingerprinted with the default profile
+func (db *fakeDB) addSong(id int, name string, fingerprints []fingerprint.Fingerprint) {
+	stored := make([]fingerprint.Fingerprint, len(fingerprints))
+	for i, fp := range fingerprints {
+		fp.SongID = id
+		stored[i] = fp
+	}
+	db.songs[id] = database.Song{ID: id, Name: name, Fingerprinted: true, TotalHashes: len(stored),
+		HashVersion: fingerprint.HASH_VERSION, Profile: PROFILE_DEFAULT}
+	db.fingerprints[id] = stored
+}
+
+// passes reports whether the song passes the parts of the filter the tests use
+func (db *fakeDB) passes(songID int, filter database.Filter) bool {
+	if len(filter.SongIDs) > 0 && !slices.Contains(filter.SongIDs, songID) {
+		return false
+	}
+	if slices.Contains(filter.ExcludeSongIDs, songID) {
+		return false
+	}
+	return filter.Profile == "" || db.songs[songID].Profile == filter.Profile
+}
+
+func (db *fakeDB) GetNumSongs(_ context.Context, filter database.Filter) (int, error) {
+	if db.err != nil {
+		return 0, db.err
+	}
+	n := 0
+	for id := range db.songs {
+		if db.passes(id, filter) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (db *fakeDB) GetSongByID(songID int) (database.Song, error) {
+	song, ok := db.songs[songID]
+	if !ok {
+		return database.Song{}, fmt.Errorf("song %d not found", songID)
+	}
+	return song, nil
+}
+
+func (db *fakeDB) GetSongsByIDs(_ context.Context, songIDs []int) (map[int]database.Song, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	songs := make(map[int]database.Song)
+	for _, id := range songIDs {
+		if song, ok := db.songs[id]; ok {
+			songs[id] = song
+		}
+	}
+	return songs, nil
+}
+
+func (db *fakeDB) ListSongs() ([]database.Song, error) {
+	var songs []database.Song
+	for _, song := range db.songs {
+		songs = append(songs, song)
+	}
+	slices.SortFunc(songs, func(a, b database.Song) int { return a.ID - b.ID })
+	return songs, nil
+}
+
+func (db *fakeDB) ReturnMatches(_ context.Context, hashes []string, filter database.Filter) ([]fingerprint.Fingerprint, error) {
+	db.lookups++
+	if db.err != nil {
+		return nil, db.err
+	}
+	wanted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		wanted[hash] = true
+	}
+	var matches []fingerprint.Fingerprint
+	for id, fingerprints := range db.fingerprints {
+		if !db.passes(id, filter) {
+			continue
+		}
+		for _, fp := range fingerprints {
+			if wanted[fp.Hash] {
+				matches = append(matches, fp)
+			}
+		}
+	}
+	return matches, nil
+}
+
+func (db *fakeDB) GetSongFingerprints(songID int) ([]fingerprint.Fingerprint, error) {
+	return db.fingerprints[songID], nil
+}
+
+func (db *fakeDB) GetSongMarkers(songID int) ([]database.Marker, error) {
+	return db.markers[songID], nil
+}
+
+func (db *fakeDB) SetSongMarkers(songID int, markers []database.Marker) error {
+	db.markers[songID] = markers
+	return nil
+}
+
+func (db *fakeDB) GetSongsMarkers(_ context.Context, songIDs []int) (map[int][]database.Marker, error) {
+	markers := make(map[int][]database.Marker)
+	for _, id := range songIDs {
+		if m, ok := db.markers[id]; ok {
+			markers[id] = m
+		}
+	}
+	return markers, nil
+}
+
+func (db *fakeDB) GetHashSongCounts(_ context.Context, hashes []string, filter database.Filter) (map[string]int, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	wanted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		wanted[hash] = true
+	}
+	counts := make(map[string]int)
+	for id, fingerprints := range db.fingerprints {
+		if !db.passes(id, filter) {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, fp := range fingerprints {
+			if wanted[fp.Hash] && !seen[fp.Hash] {
+				seen[fp.Hash] = true
+				counts[fp.Hash]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (db *fakeDB) InsertUnknownQuery(query database.UnknownQuery) (int, error) {
+	query.ID = len(db.unknown) + 1
+	db.unknown = append(db.unknown, query)
+	return query.ID, nil
+}
+
+func (db *fakeDB) GetUnknownQueries(hashVersion int, profile string) ([]database.UnknownQuery, error) {
+	var queries []database.UnknownQuery
+	for _, query := range db.unknown {
+		if query.HashVersion == hashVersion && query.Profile == profile {
+			queries = append(queries, query)
+		}
+	}
+	return queries, nil
+}
+
+func (db *fakeDB) DeleteUnknownQueries(queryIDs []int) error {
+	db.unknown = slices.DeleteFunc(db.unknown, func(query database.UnknownQuery) bool {
+		return slices.Contains(queryIDs, query.ID)
+	})
+	return nil
+}
+
+// newTestEureka returns an Eureka searching db with the default profile
+func newTestEureka(db database.Database) *Eureka {
+	e := &Eureka{database: db}
+	e.Config.Config.Profile = PROFILE_DEFAULT
+	return e
+}
+
+// testTones synthesizes seconds of audio at testSampleRate as a sequence of
+// random chords, each seed giving a different piece with distinct peaks
+func testTones(seed int64, seconds float64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	samples := make([]float64, int(seconds*testSampleRate))
+	noteLength := testSampleRate / 8
+
+	var freqs [3]float64
+	for i := range samples {
+		if i%noteLength == 0 {
+			for j := range freqs {
+				freqs[j] = 200 + rng.Float64()*3000
+			}
+		}
+		t := float64(i) / testSampleRate
+		for _, freq := range freqs {
+			samples[i] += 0.3 * math.Sin(2*math.Pi*freq*t)
+		}
+	}
+	return samples
+}
+
+// testSilence returns seconds of silence at testSampleRate
+func testSilence(seconds float64) []float64 {
+	return make([]float64, int(seconds*testSampleRate))
+}
+
+// testPCM encodes mono samples as 16-bit little-endian PCM
+func testPCM(samples []float64) []byte {
+	pcm := make([]byte, 2*len(samples))
+	for i, sample := range samples {
+		value := int16(math.Max(-1, math.Min(1, sample)) * math.MaxInt16)
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(value))
+	}
+	return pcm
+}
+
+// testSongFingerprints fingerprints samples with the default profile
+func testSongFingerprints(t *testing.T, samples []float64) []fingerprint.Fingerprint {
+	t.Helper()
+	fingerprints, err := fingerprint.FingerprintSamples(samples, testSampleRate, fingerprint.DefaultProfile)
+	if err != nil {
+		t.Fatalf("FingerprintSamples() error = %v", err)
+	}
+	return fingerprints
+}
@@ -0,0 +1,213 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	config "github.com/media-luna/eureka/configs"
+	"github.com/media-luna/eureka/internal/database"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	DEFAULT_CATALOGUE_TIMEOUT_SECONDS = 5.0 // Time a catalogue has to answer when recognition.catalogue_timeout_seconds is not set
+)
+
+// catalogue is one of the databases searched by a federated recognition
+type catalogue struct {
+	name   string
+	eureka *Eureka // Shares the configuration, reads from the catalogue's database
+}
+
+// catalogueReply is the answer of a single catalogue to a federated query
+type catalogueReply[T any] struct {
+	index int
+	name  string
+	value T
+	err   error
+}
+
+// openCatalogues connects to the catalogues listed in the config. A catalogue
+// that cannot be reached is reported and left out, so one backend being down
+// does not prevent searching the others.
+//
+// Parameters:
+//   - cfg: The application configuration listing the catalogues.
+//
+// Returns:
+//   - The connected catalogues, empty when none are configured.
+//   - An error if catalogues are configured but none could be opened.
+func openCatalogues(cfg config.Config) ([]catalogue, error) {
+	var catalogues []catalogue
+	var failures []string
+	for _, entry := range cfg.Catalogues {
+		catalogueCfg := cfg
+		catalogueCfg.Database = entry.Database
+		catalogueCfg.Catalogues = nil
+
+		db, err := database.NewDatabase(catalogueCfg)
+		if err == nil {
+			err = db.Setup()
+		}
+		if err != nil {
+			logger.Error(fmt.Errorf("error opening catalogue %s: %v", entry.Name, err))
+			failures = append(failures, entry.Name)
+			continue
+		}
+
+		catalogues = append(catalogues, catalogue{
+			name:   entry.Name,
+			eureka: &Eureka{Config: catalogueCfg, database: db},
+		})
+	}
+
+	if len(cfg.Catalogues) > 0 && len(catalogues) == 0 {
+		return nil, fmt.Errorf("no catalogue could be opened: %s", strings.Join(failures, ", "))
+	}
+	logger.Info(fmt.Sprintf("Searching %d catalogues", len(catalogues)))
+
+	return catalogues, nil
+}
+
+// queryCatalogues runs a query against every catalogue in parallel and
+// collects the answers received within recognition.catalogue_timeout_seconds.
+// The context given to the query is cancelled at the timeout, so the database
+// queries of a catalogue that is too slow are abandoned instead of running on
+// in the background. Catalogues that fail or time out are reported through
+// logger.Error and OnCatalogueError and left out of the answers. Without
+// configured catalogues the query runs once against the main database and its
+// error is returned as is.
+//
+// Parameters:
+//   - e: The Eureka instance holding the catalogues.
+//   - query: Runs the query against the catalogue at the given index.
+//
+// Returns:
+//   - The answers of the catalogues that succeeded, in configuration order.
+//   - Whether every catalogue answered, the answers are partial otherwise.
+//   - An error if no catalogue answered.
+func queryCatalogues[T any](e *Eureka, query func(ctx context.Context, index int, c *Eureka) (T, error)) ([]catalogueReply[T], bool, error) {
+	if len(e.catalogues) == 0 {
+		value, err := query(context.Background(), 0, e)
+		if err != nil {
+			return nil, false, err
+		}
+		return []catalogueReply[T]{{value: value}}, true, nil
+	}
+
+	timeout := e.Config.Recognition.CatalogueTimeoutSeconds
+	if timeout <= 0 {
+		timeout = DEFAULT_CATALOGUE_TIMEOUT_SECONDS
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout*float64(time.Second)))
+	defer cancel()
+
+	// Buffered so that catalogues answering after the timeout do not block
+	answers := make(chan catalogueReply[T], len(e.catalogues))
+	for i, c := range e.catalogues {
+		go func(i int, c catalogue) {
+			value, err := query(ctx, i, c.eureka)
+			answers <- catalogueReply[T]{index: i, name: c.name, value: value, err: err}
+		}(i, c)
+	}
+
+	pending := make(map[int]bool, len(e.catalogues))
+	for i := range e.catalogues {
+		pending[i] = true
+	}
+
+	var replies []catalogueReply[T]
+	for len(pending) > 0 {
+		select {
+		case reply := <-answers:
+			delete(pending, reply.index)
+			if reply.err != nil {
+				e.reportCatalogueError(reply.name, reply.err)
+				continue
+			}
+			replies = append(replies, reply)
+		case <-ctx.Done():
+			for i := range pending {
+				e.reportCatalogueError(e.catalogues[i].name, fmt.Errorf("no answer within %.1fs", timeout))
+			}
+			pending = nil
+		}
+	}
+
+	if len(replies) == 0 {
+		return nil, false, fmt.Errorf("no catalogue answered the query")
+	}
+	sort.Slice(replies, func(i, j int) bool { return replies[i].index < replies[j].index })
+
+	return replies, len(replies) == len(e.catalogues), nil
+}
+
+// catalogueNamed returns the catalogue with the given name, or e itself for
+// an empty name
+func (e *Eureka) catalogueNamed(name string) (*Eureka, error) {
+	if name == "" {
+		return e, nil
+	}
+	for _, c := range e.catalogues {
+		if c.name == name {
+			return c.eureka, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown catalogue %s", name)
+}
+
+// reportCatalogueError reports a catalogue that failed a federated query
+func (e *Eureka) reportCatalogueError(name string, err error) {
+	logger.Error(fmt.Errorf("catalogue %s: %v", name, err))
+	if e.OnCatalogueError != nil {
+		e.OnCatalogueError(name, err)
+	}
+}
+
+// federate runs a recognition against every catalogue and merges the ranked
+// results. It also reports whether every catalogue answered, see
+// queryCatalogues.
+func (e *Eureka) federate(recognize func(ctx context.Context, c *Eureka) ([]Result, error)) ([]Result, bool, error) {
+	replies, complete, err := queryCatalogues(e, func(ctx context.Context, _ int, c *Eureka) ([]Result, error) {
+		return recognize(ctx, c)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	lists := make([][]Result, len(replies))
+	names := make([]string, len(replies))
+	for i, reply := range replies {
+		lists[i], names[i] = reply.value, reply.name
+	}
+
+	return e.mergeResults(lists, names), complete, nil
+}
+
+// mergeResults tags the results of each catalogue with its name and keeps the
+// best recognition.top_results of them. IDF scores depend on the size and
+// content of the catalogue they were computed in, so results from several
+// catalogues are ranked by aligned hashes, with the score only breaking ties.
+func (e *Eureka) mergeResults(lists [][]Result, names []string) []Result {
+	var merged []Result
+	for i, results := range lists {
+		for _, result := range results {
+			result.Catalogue = names[i]
+			merged = append(merged, result)
+		}
+	}
+
+	if len(lists) > 1 {
+		sort.SliceStable(merged, func(i, j int) bool {
+			if merged[i].AlignedHashes != merged[j].AlignedHashes {
+				return merged[i].AlignedHashes > merged[j].AlignedHashes
+			}
+			return merged[i].Score > merged[j].Score
+		})
+	}
+
+	return limitResults(merged, e.topResults())
+}
@@ -0,0 +1,154 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestMergeResults(t *testing.T) {
+	tests := []struct {
+		name       string
+		lists      [][]Result
+		names      []string
+		topResults int
+		want       []Result // Only Catalogue and SongID are compared
+	}{
+		{
+			name:  "main database keeps its order",
+			lists: [][]Result{{{SongID: 1, AlignedHashes: 10, Score: 9}, {SongID: 2, AlignedHashes: 20, Score: 5}}},
+			names: []string{""}, topResults: 2,
+			want: []Result{{SongID: 1}, {SongID: 2}},
+		},
+		{
+			name: "catalogues ranked by aligned hashes",
+			lists: [][]Result{
+				{{SongID: 1, AlignedHashes: 10, Score: 90}},
+				{{SongID: 1, AlignedHashes: 30, Score: 3}, {SongID: 2, AlignedHashes: 20, Score: 2}},
+			},
+			names: []string{"a", "b"}, topResults: 3,
+			want: []Result{{Catalogue: "b", SongID: 1}, {Catalogue: "b", SongID: 2}, {Catalogue: "a", SongID: 1}},
+		},
+		{
+			name: "score breaks ties",
+			lists: [][]Result{
+				{{SongID: 1, AlignedHashes: 10, Score: 1}},
+				{{SongID: 2, AlignedHashes: 10, Score: 2}},
+			},
+			names: []string{"a", "b"}, topResults: 2,
+			want: []Result{{Catalogue: "b", SongID: 2}, {Catalogue: "a", SongID: 1}},
+		},
+		{
+			name: "default top results",
+			lists: [][]Result{
+				{{SongID: 1, AlignedHashes: 10}},
+				{{SongID: 2, AlignedHashes: 20}},
+			},
+			names: []string{"a", "b"},
+			want:  []Result{{Catalogue: "b", SongID: 2}},
+		},
+		{
+			name:  "no results",
+			lists: [][]Result{nil, nil},
+			names: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Eureka{}
+			e.Config.Recognition.TopResults = tt.topResults
+
+			var got []Result
+			for _, result := range e.mergeResults(tt.lists, tt.names) {
+				got = append(got, Result{Catalogue: result.Catalogue, SongID: result.SongID})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeResults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryCatalogues(t *testing.T) {
+	tests := []struct {
+		name         string
+		catalogues   []string // "ok", "down" or "slow" for each catalogue
+		want         []int    // Indexes of the catalogues that answered
+		wantComplete bool
+		wantFailed   []string
+		wantErr      bool
+	}{
+		{name: "main database only", want: []int{0}, wantComplete: true},
+		{name: "every catalogue answers", catalogues: []string{"ok", "ok", "ok"}, want: []int{0, 1, 2}, wantComplete: true},
+		{name: "failed catalogue left out", catalogues: []string{"ok", "down", "ok"}, want: []int{0, 2}, wantFailed: []string{"c1"}},
+		{name: "slow catalogue left out", catalogues: []string{"slow", "ok"}, want: []int{1}, wantFailed: []string{"c0"}},
+		{name: "no catalogue answers", catalogues: []string{"down", "slow"}, wantFailed: []string{"c0", "c1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Eureka{}
+			e.Config.Recognition.CatalogueTimeoutSeconds = 0.05
+			for i := range tt.catalogues {
+				e.catalogues = append(e.catalogues, catalogue{name: fmt.Sprintf("c%d", i), eureka: &Eureka{}})
+			}
+
+			// Every catalogue answers with its index, unless it is down or
+			// too slow to answer before the timeout. Slow catalogues answer
+			// after the subtest ends, so they must not read tt.
+			behaviours := tt.catalogues
+			answer := func(ctx context.Context, index int, c *Eureka) (int, error) {
+				if index >= len(behaviours) {
+					return index, nil
+				}
+				switch behaviours[index] {
+				case "down":
+					return 0, errors.New("connection refused")
+				case "slow":
+					<-ctx.Done()
+					return 0, ctx.Err()
+				}
+				return index, nil
+			}
+
+			var mu sync.Mutex
+			var failed []string
+			e.OnCatalogueError = func(name string, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				failed = append(failed, name)
+			}
+
+			replies, complete, err := queryCatalogues(e, answer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("queryCatalogues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+
+			var got []int
+			for _, reply := range replies {
+				if reply.value != reply.index {
+					t.Errorf("reply of catalogue %d holds %d", reply.index, reply.value)
+				}
+				got = append(got, reply.index)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("answers from %v, want %v", got, tt.want)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			sort.Strings(failed)
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("failed catalogues %v, want %v", failed, tt.wantFailed)
+			}
+		})
+	}
+}
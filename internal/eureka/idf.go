@@ -0,0 +1,104 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/media-luna/eureka/internal/database"
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	IDF_MIN_SONGS = 20 // Catalogue size below which common hashes are weighted but never skipped
+)
+
+// lookup fetches the stored fingerprints sharing a hash with the query from
+// the songs passing the filter set by WithFilter, so that excluded songs never
+// compete for the top results. When recognition.idf_weighting is set it also
+// returns an inverse document frequency weight for every hash, and hashes held
+// by more than recognition.idf_max_song_ratio of the catalogue are not looked
+// up at all, since hashes from silence, hum or common drum hits match almost
+// every song and only add noise to the offset histograms.
+//
+// Parameters:
+//   - ctx: Cancels the database queries, see queryCatalogues.
+//   - hashes: The distinct query hashes.
+//
+// Returns:
+//   - The stored fingerprints sharing a hash with the query.
+//   - The weight of each hash, nil when weighting is disabled.
+//   - An error if a lookup failed.
+func (e *Eureka) lookup(ctx context.Context, hashes []string) ([]fingerprint.Fingerprint, map[string]float64, error) {
+	var weights map[string]float64
+
+	if e.Config.Recognition.IDFWeighting {
+		var err error
+		weights, hashes, err = e.hashWeights(ctx, hashes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	matches, err := e.database.ReturnMatches(ctx, hashes, e.searchFilter())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error looking up fingerprints: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Found %d matching hashes in database", len(matches)))
+
+	return matches, weights, nil
+}
+
+// searchFilter returns the filter set by WithFilter, restricted to the songs
+// fingerprinted with the selected profile when the database holds songs of
+// another profile, see checkProfile
+func (e *Eureka) searchFilter() database.Filter {
+	filter := e.filter
+	if e.mixedProfiles {
+		filter.Profile = e.Config.Config.Profile
+	}
+	return filter
+}
+
+// hashWeights computes the inverse document frequency of each hash from the
+// number of songs passing the filter that hold it, and returns the hashes
+// worth looking up.
+func (e *Eureka) hashWeights(ctx context.Context, hashes []string) (map[string]float64, []string, error) {
+	numSongs, err := e.database.GetNumSongs(ctx, e.searchFilter())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error counting songs: %v", err)
+	}
+
+	counts, err := e.database.GetHashSongCounts(ctx, hashes, e.searchFilter())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error looking up hash stats: %v", err)
+	}
+
+	maxSongs := math.Inf(1)
+	if ratio := e.Config.Recognition.IDFMaxSongRatio; ratio > 0 && numSongs >= IDF_MIN_SONGS {
+		maxSongs = ratio * float64(numSongs)
+	}
+
+	weights := make(map[string]float64, len(hashes))
+	kept := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		// Hashes without stats, such as those of a song stored while the
+		// stats were rebuilt, are counted as held by a single song
+		count := counts[hash]
+		if count < 1 {
+			count = 1
+		}
+		if float64(count) > maxSongs {
+			continue
+		}
+		weights[hash] = math.Log(1 + float64(numSongs)/float64(count))
+		kept = append(kept, hash)
+	}
+
+	if skipped := len(hashes) - len(kept); skipped > 0 {
+		logger.Info(fmt.Sprintf("Skipped %d common hashes", skipped))
+	}
+
+	return weights, kept, nil
+}
@@ -0,0 +1,90 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/media-luna/eureka/internal/database"
+	"github.com/media-luna/eureka/internal/fingerprint"
+)
+
+func TestHashWeights(t *testing.T) {
+	// newCatalogue stores numSongs songs holding the common hash, the first
+	// one also holding the rare hash
+	newCatalogue := func(numSongs int) *fakeDB {
+		db := newFakeDB()
+		for id := 1; id <= numSongs; id++ {
+			fingerprints := []fingerprint.Fingerprint{testFingerprint("common", 0, id)}
+			if id == 1 {
+				fingerprints = append(fingerprints, testFingerprint("rare", 100, id))
+			}
+			db.addSong(id, "song", fingerprints)
+		}
+		return db
+	}
+	hashes := []string{"common", "rare", "unknown"}
+
+	tests := []struct {
+		name    string
+		db      *fakeDB
+		filter  database.Filter
+		ratio   float64
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name:  "small catalogue keeps common hashes",
+			db:    newCatalogue(3),
+			ratio: 0.5,
+			want:  map[string]float64{"common": math.Log(2), "rare": math.Log(4), "unknown": math.Log(4)},
+		},
+		{
+			name:  "common hashes skipped",
+			db:    newCatalogue(IDF_MIN_SONGS),
+			ratio: 0.5,
+			want:  map[string]float64{"rare": math.Log(1 + IDF_MIN_SONGS), "unknown": math.Log(1 + IDF_MIN_SONGS)},
+		},
+		{
+			name: "no ratio skips nothing",
+			db:   newCatalogue(IDF_MIN_SONGS),
+			want: map[string]float64{"common": math.Log(2), "rare": math.Log(1 + IDF_MIN_SONGS), "unknown": math.Log(1 + IDF_MIN_SONGS)},
+		},
+		{
+			name:   "only songs passing the filter counted",
+			db:     newCatalogue(3),
+			filter: database.Filter{ExcludeSongIDs: []int{1}},
+			want:   map[string]float64{"common": math.Log(2), "rare": math.Log(3), "unknown": math.Log(3)},
+		},
+		{
+			name:    "lookup error",
+			db:      &fakeDB{err: errors.New("connection lost")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEureka(tt.db).WithFilter(tt.filter)
+			e.Config.Recognition.IDFMaxSongRatio = tt.ratio
+
+			weights, kept, err := e.hashWeights(context.Background(), hashes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hashWeights() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(weights) != len(tt.want) || len(kept) != len(tt.want) {
+				t.Fatalf("hashWeights() = %v, %v, want %v", weights, kept, tt.want)
+			}
+			for _, hash := range kept {
+				want, ok := tt.want[hash]
+				if !ok {
+					t.Errorf("hash %s kept", hash)
+				}
+				if math.Abs(weights[hash]-want) > 1e-9 {
+					t.Errorf("weight of %s = %v, want %v", hash, weights[hash], want)
+				}
+			}
+		})
+	}
+}
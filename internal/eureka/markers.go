@@ -0,0 +1,114 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/media-luna/eureka/internal/database"
+)
+
+// Section is the named part of a song a recognized query was heard in
+type Section struct {
+	Name         string  `json:"name"`
+	StartSeconds float64 `json:"start_seconds"`
+	EndSeconds   float64 `json:"end_seconds,omitempty"` // Start of the next marker, 0 when the section lasts until the end of the song
+}
+
+// SetMarkers replaces the markers of a song. Recognition results then report
+// the section of the song their offset falls in.
+//
+// Parameters:
+//   - catalogue: The name of the catalogue holding the song, as reported in
+//     Result.Catalogue, empty for the main database.
+//   - songID: The ID of the song to mark.
+//   - markers: The markers of the song, in any order, empty to remove them.
+//
+// Returns:
+//   - An error if the catalogue or song does not exist, two markers share a
+//     start or the markers could not be stored.
+func (e *Eureka) SetMarkers(catalogue string, songID int, markers []database.Marker) error {
+	owner, err := e.catalogueNamed(catalogue)
+	if err != nil {
+		return err
+	}
+	if _, err := owner.database.GetSongByID(songID); err != nil {
+		return err
+	}
+
+	starts := make(map[int]string, len(markers))
+	for _, marker := range markers {
+		if marker.Name == "" {
+			return fmt.Errorf("marker at %dms has no name", marker.Start)
+		}
+		if marker.Start < 0 {
+			return fmt.Errorf("marker %s starts before the song", marker.Name)
+		}
+		if other, ok := starts[marker.Start]; ok {
+			return fmt.Errorf("markers %s and %s both start at %dms", other, marker.Name, marker.Start)
+		}
+		starts[marker.Start] = marker.Name
+	}
+
+	defer e.cache.clear()
+	return owner.database.SetSongMarkers(songID, markers)
+}
+
+// Markers returns the markers of a song of the named catalogue, empty for the
+// main database, ordered by start
+func (e *Eureka) Markers(catalogue string, songID int) ([]database.Marker, error) {
+	owner, err := e.catalogueNamed(catalogue)
+	if err != nil {
+		return nil, err
+	}
+	return owner.database.GetSongMarkers(songID)
+}
+
+// setSections sets the section of every result from the markers of the
+// catalogue that returned it, loading the markers of each catalogue with a
+// single query
+func (e *Eureka) setSections(ctx context.Context, results []Result) error {
+	byCatalogue := make(map[string][]int)
+	for _, result := range results {
+		byCatalogue[result.Catalogue] = append(byCatalogue[result.Catalogue], result.SongID)
+	}
+
+	markers := make(map[string]map[int][]database.Marker, len(byCatalogue))
+	for name, songIDs := range byCatalogue {
+		owner, err := e.catalogueNamed(name)
+		if err != nil {
+			return err
+		}
+		songMarkers, err := owner.database.GetSongsMarkers(ctx, songIDs)
+		if err != nil {
+			return fmt.Errorf("error loading song markers: %v", err)
+		}
+		markers[name] = songMarkers
+	}
+
+	for i := range results {
+		results[i].Section = sectionAt(markers[results[i].Catalogue][results[i].SongID], max(results[i].Offset, 0))
+	}
+
+	return nil
+}
+
+// sectionAt finds the marker range holding offset, in milliseconds
+func sectionAt(markers []database.Marker, offset int) *Section {
+	sorted := append([]database.Marker(nil), markers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	// Index of the first marker starting after the offset
+	next := sort.Search(len(sorted), func(i int) bool { return sorted[i].Start > offset })
+	if next == 0 {
+		return nil
+	}
+
+	marker := sorted[next-1]
+	section := &Section{Name: marker.Name, StartSeconds: float64(marker.Start) / 1000}
+	if next < len(sorted) {
+		section.EndSeconds = float64(sorted[next].Start) / 1000
+	}
+
+	return section
+}
@@ -0,0 +1,35 @@
+package eureka
+
+import (
+	"testing"
+
+	"github.com/media-luna/eureka/internal/database"
+)
+
+func TestSectionAt(t *testing.T) {
+	// Markers in any order
+	markers := []database.Marker{{Name: "chorus", Start: 5000}, {Name: "intro", Start: 1000}, {Name: "outro", Start: 9000}}
+
+	tests := []struct {
+		name    string
+		markers []database.Marker
+		offset  int
+		want    *Section
+	}{
+		{name: "no markers", offset: 3000},
+		{name: "before the first marker", markers: markers, offset: 999},
+		{name: "at a marker", markers: markers, offset: 1000, want: &Section{Name: "intro", StartSeconds: 1, EndSeconds: 5}},
+		{name: "inside a section", markers: markers, offset: 7000, want: &Section{Name: "chorus", StartSeconds: 5, EndSeconds: 9}},
+		{name: "end belongs to the next section", markers: markers, offset: 5000, want: &Section{Name: "chorus", StartSeconds: 5, EndSeconds: 9}},
+		{name: "last section lasts until the end", markers: markers, offset: 60000, want: &Section{Name: "outro", StartSeconds: 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sectionAt(tt.markers, tt.offset)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package eureka
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	DEFAULT_MONITOR_WINDOW_SECONDS     = 10.0 // Window length used when monitor.window_seconds is not set
+	DEFAULT_MONITOR_HOP_SECONDS        = 5.0  // Step between windows used when monitor.hop_seconds is not set
+	DEFAULT_MONITOR_MIN_ALIGNED_HASHES = 10   // Aligned hashes a window needs to count when monitor.min_aligned_hashes is not set
+	MONITOR_OFFSET_TOLERANCE_MS        = 500  // Max drift between windows of the same segment, in milliseconds
+)
+
+// Segment represents a song detected over a continuous part of a recording
+type Segment struct {
+	Catalogue  string // Name of the catalogue the song was found in, empty unless catalogues are configured
+	SongID     int
+	SongName   string
+	Artist     string
+	Start      float64 // Start of the segment in the recording, in seconds
+	End        float64 // End of the segment in the recording, in seconds
+	SongOffset float64 // Position in the song heard at Start, in seconds
+	Confidence float64 // Mean input confidence of the windows in the segment
+	Windows    int     // Number of windows that agreed on the song
+}
+
+// monitor slides a window over a recording, recognizes each window and merges
+// consecutive windows that agree on a song into segments.
+type monitor struct {
+	eureka     *Eureka
+	source     string
+	sampleRate int
+	window     int // Window length in samples
+	hop        int // Step between windows in samples
+
+	buffer   []float64
+	position int // Position of buffer[0] in the recording, in samples
+	covered  int // Samples at the start of buffer already part of a processed window
+
+	segments []Segment
+	current  *Segment
+	anchor   float64 // Song offset minus recording time of the current segment, in seconds
+	missed   int
+	recorded int // End of the last window recorded as unknown, in samples
+	read     int // Samples read from the recording so far
+}
+
+// MonitorFile builds a timeline of the songs heard in a long recording. The
+// recording is converted to a temporary WAV file and read a hop at a time, so
+// only a window of audio is held in memory however long the recording is.
+//
+// Parameters:
+//   - path: The path to the recording.
+//
+// Returns:
+//   - The detected segments in chronological order.
+//   - An error if the recording could not be processed or a lookup failed.
+func (e *Eureka) MonitorFile(path string) ([]Segment, error) {
+	tmp, err := os.CreateTemp("", "eureka-*.wav")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	filePath, err := fingerprint.ConvertToWAV(path, tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error converting to WAV: %v", err)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening WAV file: %v", err)
+	}
+	defer f.Close()
+
+	header, err := fingerprint.ReadWavHeader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading WAV info: %v", err)
+	}
+	if header.SampleRate == 0 || header.NumChannels == 0 {
+		return nil, fmt.Errorf("invalid WAV format in %s", path)
+	}
+
+	var r io.Reader = f
+	if header.Subchunk2Size > 0 {
+		r = io.LimitReader(f, int64(header.Subchunk2Size))
+	}
+
+	m := e.newMonitor(path, int(header.SampleRate))
+	return m.run(r, int(header.NumChannels), nil)
+}
+
+// MonitorStream builds a timeline of the songs heard in a stream of raw
+// interleaved 16-bit little-endian PCM, reading until EOF.
+//
+// Parameters:
+//   - r: The reader providing the PCM audio.
+//   - sampleRate: The sample rate of the audio.
+//   - channels: The number of interleaved channels.
+//   - onSegment: Called with every segment once it is complete, may be nil.
+//
+// Returns:
+//   - The detected segments in chronological order.
+//   - An error if reading, decoding or a lookup failed.
+func (e *Eureka) MonitorStream(r io.Reader, sampleRate int, channels int, onSegment func(Segment)) ([]Segment, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("sample rate must be positive")
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("channel count must be positive")
+	}
+
+	m := e.newMonitor("stream", sampleRate)
+	return m.run(r, channels, onSegment)
+}
+
+// run reads PCM audio from r a hop at a time until EOF, recognizing every
+// complete window, and calls onSegment, if set, with every completed segment.
+func (m *monitor) run(r io.Reader, channels int, onSegment func(Segment)) ([]Segment, error) {
+	buf := make([]byte, m.hop*channels*2)
+	reported := 0
+
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return m.segments, fmt.Errorf("error reading stream: %v", readErr)
+		}
+
+		n -= n % (channels * 2)
+		if n > 0 {
+			samples, err := fingerprint.DecodePCM16(buf[:n], channels)
+			if err != nil {
+				return m.segments, fmt.Errorf("error decoding PCM: %v", err)
+			}
+			if err := m.feed(samples); err != nil {
+				return m.segments, err
+			}
+		}
+
+		if readErr != nil {
+			if err := m.flush(); err != nil {
+				return m.segments, err
+			}
+		}
+
+		if onSegment != nil {
+			for ; reported < len(m.segments); reported++ {
+				onSegment(m.segments[reported])
+			}
+		}
+
+		if readErr != nil {
+			return m.segments, nil
+		}
+	}
+}
+
+// newMonitor creates a monitor using the window settings of the selected
+// profile, see thresholds
+func (e *Eureka) newMonitor(source string, sampleRate int) *monitor {
+	thresholds := e.thresholds()
+	windowSeconds := thresholds.monitorWindowSeconds
+	if windowSeconds <= 0 {
+		windowSeconds = DEFAULT_MONITOR_WINDOW_SECONDS
+	}
+	hopSeconds := thresholds.monitorHopSeconds
+	if hopSeconds <= 0 || hopSeconds > windowSeconds {
+		hopSeconds = math.Min(DEFAULT_MONITOR_HOP_SECONDS, windowSeconds)
+	}
+
+	return &monitor{
+		eureka:     e,
+		source:     source,
+		sampleRate: sampleRate,
+		window:     int(windowSeconds * float64(sampleRate)),
+		hop:        int(hopSeconds * float64(sampleRate)),
+	}
+}
+
+// feed appends samples to the buffer and processes every complete window
+func (m *monitor) feed(samples []float64) error {
+	m.buffer = append(m.buffer, samples...)
+	m.read += len(samples)
+
+	for len(m.buffer) >= m.window {
+		if err := m.process(m.buffer[:m.window], m.position); err != nil {
+			return err
+		}
+		m.buffer = m.buffer[m.hop:]
+		m.position += m.hop
+		m.covered = m.window - m.hop
+	}
+
+	return nil
+}
+
+// flush processes the audio left in the buffer that no window covered yet and
+// closes the open segment.
+func (m *monitor) flush() error {
+	if len(m.buffer) > m.covered && len(m.buffer) >= fingerprint.WINDOW_SIZE {
+		if err := m.process(m.buffer, m.position); err != nil {
+			return err
+		}
+	}
+	m.buffer = nil
+	m.close()
+
+	return nil
+}
+
+// process recognizes a single window and merges it into the timeline
+func (m *monitor) process(samples []float64, start int) error {
+	results, err := m.eureka.RecognizeSamples(samples, m.sampleRate)
+	if err != nil {
+		return fmt.Errorf("error recognizing window at %.1fs: %v", float64(start)/float64(m.sampleRate), err)
+	}
+
+	windowStart := float64(start) / float64(m.sampleRate)
+	windowEnd := float64(start+len(samples)) / float64(m.sampleRate)
+
+	if len(results) == 0 || results[0].AlignedHashes < m.eureka.thresholds().monitorMinAlignedHashes {
+		m.missed++
+		if m.missed > m.eureka.Config.Monitor.MaxMissedWindows {
+			m.close()
+		}
+		// Windows overlap, record only the ones that do not so that recurring
+		// unknown content is not counted twice
+		if start >= m.recorded {
+			m.recorded = start + len(samples)
+			return m.eureka.recordUnknownSamples(samples, m.sampleRate, m.source, windowStart)
+		}
+		return nil
+	}
+
+	best := results[0]
+	anchor := best.OffsetSeconds - windowStart
+
+	if m.current != nil && m.current.SongID == best.SongID && m.current.Catalogue == best.Catalogue &&
+		math.Abs(anchor-m.anchor)*1000 <= MONITOR_OFFSET_TOLERANCE_MS {
+		m.current.End = windowEnd
+		m.current.Confidence += best.InputConfidence
+		m.current.Windows++
+		m.missed = 0
+		return nil
+	}
+
+	// A negative offset means the song started inside the window
+	segmentStart, songOffset := windowStart, best.OffsetSeconds
+	if songOffset < 0 {
+		segmentStart -= songOffset
+		songOffset = 0
+	}
+
+	m.close()
+	m.current = &Segment{
+		Catalogue:  best.Catalogue,
+		SongID:     best.SongID,
+		SongName:   best.SongName,
+		Artist:     best.Artist,
+		Start:      segmentStart,
+		End:        windowEnd,
+		SongOffset: songOffset,
+		Confidence: best.InputConfidence,
+		Windows:    1,
+	}
+	m.anchor = anchor
+	m.missed = 0
+
+	return nil
+}
+
+// close finalizes the open segment, if any, and appends it to the timeline.
+// The segment is kept within the audio read, which a song starting inside the
+// last window could otherwise be placed past.
+func (m *monitor) close() {
+	if m.current == nil {
+		return
+	}
+
+	end := float64(m.read) / float64(m.sampleRate)
+	m.current.End = math.Min(m.current.End, end)
+	m.current.Start = math.Min(m.current.Start, m.current.End)
+	m.current.Confidence /= float64(m.current.Windows)
+	m.segments = append(m.segments, *m.current)
+	logger.Info(fmt.Sprintf("Detected %s from %.1fs to %.1fs", m.current.SongName, m.current.Start, m.current.End))
+	m.current = nil
+}
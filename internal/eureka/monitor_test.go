@@ -0,0 +1,88 @@
+package eureka
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestMonitorStream(t *testing.T) {
+	db := newFakeDB()
+	songA := testTones(1, 30)
+	songB := testTones(2, 30)
+	db.addSong(1, "a", testSongFingerprints(t, songA))
+	db.addSong(2, "b", testSongFingerprints(t, songB))
+
+	concat := func(parts ...[]float64) []float64 {
+		var samples []float64
+		for _, part := range parts {
+			samples = append(samples, part...)
+		}
+		return samples
+	}
+	second := func(s float64) int { return int(s * testSampleRate) }
+
+	tests := []struct {
+		name             string
+		recording        []float64
+		maxMissedWindows int
+		want             []Segment // Only SongID, Start and SongOffset are compared, and End is checked against the window length
+	}{
+		{
+			name:      "silence",
+			recording: testSilence(30),
+		},
+		{
+			name:      "song starting inside a window",
+			recording: concat(testSilence(7), songA[:second(20)], testSilence(8)),
+			want:      []Segment{{SongID: 1, Start: 7, End: 27}},
+		},
+		{
+			name:      "two songs back to back",
+			recording: concat(songA[:second(20)], songB[second(10):second(30)], testSilence(5)),
+			want:      []Segment{{SongID: 1, Start: 0, End: 20}, {SongID: 2, Start: 20, End: 40, SongOffset: 10}},
+		},
+		{
+			name:      "gap closes the segment",
+			recording: concat(songA[:second(10)], testSilence(10), songA[second(20):second(30)]),
+			want:      []Segment{{SongID: 1, Start: 0, End: 10}, {SongID: 1, Start: 15, End: 30, SongOffset: 15}},
+		},
+		{
+			name:             "gap within the missed windows allowed",
+			recording:        concat(songA[:second(10)], testSilence(10), songA[second(20):second(30)]),
+			maxMissedWindows: 1,
+			want:             []Segment{{SongID: 1, Start: 0, End: 30}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEureka(db)
+			e.Config.Monitor.MinAlignedHashes = 20
+			e.Config.Monitor.MaxMissedWindows = tt.maxMissedWindows
+
+			var reported []Segment
+			got, err := e.MonitorStream(bytes.NewReader(testPCM(tt.recording)), testSampleRate, 1, func(s Segment) {
+				reported = append(reported, s)
+			})
+			if err != nil {
+				t.Fatalf("MonitorStream() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("MonitorStream() = %+v, want %d segments", got, len(tt.want))
+			}
+			if len(reported) != len(got) {
+				t.Errorf("onSegment called %d times, want %d", len(reported), len(got))
+			}
+			for i, want := range tt.want {
+				s := got[i]
+				if s.SongID != want.SongID || math.Abs(s.Start-want.Start) > 0.1 || math.Abs(s.SongOffset-want.SongOffset) > 0.1 {
+					t.Errorf("segment %d = %+v, want song %d from %.1fs at offset %.1fs", i, s, want.SongID, want.Start, want.SongOffset)
+				}
+				if s.End < want.End-0.1 || s.End > want.End+DEFAULT_MONITOR_WINDOW_SECONDS {
+					t.Errorf("segment %d ends at %.1fs, want between %.1fs and %.1fs", i, s.End, want.End, want.End+DEFAULT_MONITOR_WINDOW_SECONDS)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package eureka
+
+import (
+	"math"
+)
+
+const (
+	DEFAULT_PITCH_MAX_SEMITONES  = 3.0 // Largest pitch shift searched when recognition.pitch_max_semitones is not set
+	DEFAULT_PITCH_STEP_SEMITONES = 0.5 // Step between searched shifts when recognition.pitch_step_semitones is not set
+)
+
+// pitchShifts returns the semitone shifts to search, symmetric around 0
+func pitchShifts(maxSemitones float64, step float64) []float64 {
+	if maxSemitones <= 0 {
+		maxSemitones = DEFAULT_PITCH_MAX_SEMITONES
+	}
+	if step <= 0 {
+		step = DEFAULT_PITCH_STEP_SEMITONES
+	}
+
+	steps := int(math.Round(maxSemitones / step))
+	shifts := make([]float64, 0, 2*steps+1)
+	for i := -steps; i <= steps; i++ {
+		shifts = append(shifts, float64(i)*step)
+	}
+
+	return shifts
+}
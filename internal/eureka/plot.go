@@ -0,0 +1,248 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	PLOT_WIDTH            = 800 // Width of the match plot, in pixels
+	PLOT_SCATTER_HEIGHT   = 600 // Height of the query offset against database offset scatter, in pixels
+	PLOT_HISTOGRAM_HEIGHT = 200 // Height of the offset difference histogram, in pixels
+	PLOT_MARGIN           = 10  // Blank border around each panel, in pixels
+)
+
+// plotColors are the colors of the plotted candidates, best result first
+var plotColors = []color.RGBA{
+	{220, 30, 30, 255},  // Red
+	{30, 90, 220, 255},  // Blue
+	{30, 160, 60, 255},  // Green
+	{230, 140, 20, 255}, // Orange
+	{140, 50, 180, 255}, // Purple
+}
+
+// matchPoint is a hash shared by the query and a candidate song
+type matchPoint struct {
+	QueryOffset    int  // Offset of the hash in the query, in milliseconds
+	DatabaseOffset int  // Offset of the hash in the song, in milliseconds
+	Aligned        bool // Whether the hash agrees with the offset of the result
+}
+
+// PlotMatches renders the hashes a query shares with its recognition results
+// to a PNG image. The top panel is a scatter of the query offset against the
+// database offset of every shared hash, where a true match shows up as a
+// diagonal line. The bottom panel is the histogram of the offset differences,
+// where a true match shows up as a single spike. Each result is drawn in its
+// own color, red for the best one, with the hashes that disagree with its
+// offset faded. Results of a tempo or pitch search are plotted with the query
+// brought back to the speed and pitch they were found at, as in matchVariants.
+//
+// Parameters:
+//   - path: The path to the audio clip that was recognized.
+//   - results: The results returned for the clip, at most len(plotColors) are drawn.
+//   - imagePath: The path of the PNG image to write.
+//
+// Returns:
+//   - An error if the clip could not be processed, the lookup failed or the
+//     image could not be written.
+func (e *Eureka) PlotMatches(path string, results []Result, imagePath string) error {
+	samples, sampleRate, err := loadSamples(path)
+	if err != nil {
+		return err
+	}
+
+	peaks, err := fingerprint.SamplesToPeaks(samples, sampleRate, e.fingerprintProfile())
+	if err != nil {
+		return err
+	}
+	query := e.queryFingerprints(peaks)
+
+	if len(results) > len(plotColors) {
+		results = results[:len(plotColors)]
+	}
+
+	points := make([][]matchPoint, len(results))
+	for i, result := range results {
+		fingerprints := query
+		if v := result.variant(); v.SpeedFactor > 0 && v.distance() > 0 {
+			fingerprints = e.queryFingerprints(fingerprint.ShiftPeaks(fingerprint.StretchPeaks(peaks, v.SpeedFactor), -v.Semitones))
+		}
+
+		owner, err := e.catalogueNamed(result.Catalogue)
+		if err != nil {
+			return err
+		}
+		points[i], err = owner.matchPoints(fingerprints, result.SongID, result.Offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeMatchPlot(points, imagePath); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Match plot saved to %s", imagePath))
+
+	return nil
+}
+
+// matchPoints looks up the query hashes and returns every pair of query and
+// database offsets of the hashes shared with the given song, marking those
+// within OFFSET_TOLERANCE_MS of offset as aligned
+func (e *Eureka) matchPoints(query []fingerprint.Fingerprint, songID int, offset int) ([]matchPoint, error) {
+	matches, _, err := e.lookup(context.Background(), uniqueHashes(query))
+	if err != nil {
+		return nil, err
+	}
+
+	queryOffsets := make(map[string][]int)
+	for _, fp := range query {
+		queryOffsets[fp.Hash] = append(queryOffsets[fp.Hash], fp.Offset)
+	}
+
+	var points []matchPoint
+	for _, m := range matches {
+		if m.SongID != songID {
+			continue
+		}
+		for _, queryOffset := range queryOffsets[m.Hash] {
+			diff := m.Offset - queryOffset - offset
+			points = append(points, matchPoint{
+				QueryOffset:    queryOffset,
+				DatabaseOffset: m.Offset,
+				Aligned:        diff >= -OFFSET_TOLERANCE_MS && diff <= OFFSET_TOLERANCE_MS,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+// writeMatchPlot draws the scatter and histogram panels of the given
+// candidates, one slice of points per candidate, and saves them as a PNG.
+func writeMatchPlot(points [][]matchPoint, path string) error {
+	img := image.NewRGBA(image.Rect(0, 0, PLOT_WIDTH, PLOT_SCATTER_HEIGHT+PLOT_HISTOGRAM_HEIGHT))
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			img.Set(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+
+	scatter := image.Rect(PLOT_MARGIN, PLOT_MARGIN, PLOT_WIDTH-PLOT_MARGIN, PLOT_SCATTER_HEIGHT-PLOT_MARGIN)
+	histogram := image.Rect(PLOT_MARGIN, PLOT_SCATTER_HEIGHT+PLOT_MARGIN, PLOT_WIDTH-PLOT_MARGIN, PLOT_SCATTER_HEIGHT+PLOT_HISTOGRAM_HEIGHT-PLOT_MARGIN)
+	drawFrame(img, scatter)
+	drawFrame(img, histogram)
+
+	// Ranges shared by every candidate so their points can be compared
+	first := true
+	var maxQuery, minDatabase, maxDatabase, minDiff, maxDiff int
+	for _, candidate := range points {
+		for _, p := range candidate {
+			diff := p.DatabaseOffset - p.QueryOffset
+			if first {
+				minDatabase, maxDatabase, minDiff, maxDiff = p.DatabaseOffset, p.DatabaseOffset, diff, diff
+				first = false
+			}
+			maxQuery = max(maxQuery, p.QueryOffset)
+			minDatabase = min(minDatabase, p.DatabaseOffset)
+			maxDatabase = max(maxDatabase, p.DatabaseOffset)
+			minDiff = min(minDiff, diff)
+			maxDiff = max(maxDiff, diff)
+		}
+	}
+
+	// Draw the weakest candidate first so the best one stays on top
+	for i := len(points) - 1; i >= 0; i-- {
+		c := plotColors[i]
+		faded := color.RGBA{fade(c.R), fade(c.G), fade(c.B), 255}
+
+		// Faded hashes first so they never hide the aligned ones
+		sorted := make([]matchPoint, 0, len(points[i]))
+		for _, p := range points[i] {
+			if !p.Aligned {
+				sorted = append(sorted, p)
+			}
+		}
+		for _, p := range points[i] {
+			if p.Aligned {
+				sorted = append(sorted, p)
+			}
+		}
+
+		for _, p := range sorted {
+			c := c
+			if !p.Aligned {
+				c = faded
+			}
+			x := scale(p.QueryOffset, 0, maxQuery, scatter.Min.X, scatter.Max.X-1)
+			y := scale(p.DatabaseOffset, minDatabase, maxDatabase, scatter.Max.Y-1, scatter.Min.Y) // Invert y-axis
+			img.Set(x, y, c)
+			img.Set(x+1, y, c)
+			img.Set(x, y+1, c)
+			img.Set(x+1, y+1, c)
+		}
+
+		// One bin per pixel column
+		bins := make([]int, histogram.Dx())
+		peak := 0
+		for _, p := range points[i] {
+			bin := scale(p.DatabaseOffset-p.QueryOffset, minDiff, maxDiff, 0, len(bins)-1)
+			bins[bin]++
+			peak = max(peak, bins[bin])
+		}
+		for bin, count := range bins {
+			if count == 0 {
+				continue
+			}
+			top := scale(count, 0, peak, histogram.Max.Y-1, histogram.Min.Y)
+			for y := top; y < histogram.Max.Y; y++ {
+				img.Set(histogram.Min.X+bin, y, c)
+			}
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating plot file: %v", err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		return fmt.Errorf("error encoding plot image: %v", err)
+	}
+
+	return nil
+}
+
+// drawFrame draws the gray border of a panel
+func drawFrame(img *image.RGBA, r image.Rectangle) {
+	frame := color.RGBA{160, 160, 160, 255}
+	for x := r.Min.X - 1; x <= r.Max.X; x++ {
+		img.Set(x, r.Min.Y-1, frame)
+		img.Set(x, r.Max.Y, frame)
+	}
+	for y := r.Min.Y - 1; y <= r.Max.Y; y++ {
+		img.Set(r.Min.X-1, y, frame)
+		img.Set(r.Max.X, y, frame)
+	}
+}
+
+// fade blends a color channel three quarters of the way to white
+func fade(channel uint8) uint8 {
+	return uint8(255 - (255-int(channel))/4)
+}
+
+// scale maps value from the range [lo, hi] to the pixel range [from, to]
+func scale(value, lo, hi, from, to int) int {
+	if hi == lo {
+		return (from + to) / 2
+	}
+	return from + (value-lo)*(to-from)/(hi-lo)
+}
@@ -0,0 +1,169 @@
+package eureka
+
+import (
+	"fmt"
+
+	config "github.com/media-luna/eureka/configs"
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	PROFILE_DEFAULT = "default" // Fingerprinting profile of full length songs
+	PROFILE_SHORT   = "short"   // Denser fingerprinting profile of jingles, station IDs and ad spots
+)
+
+// SetProfile selects the fingerprinting profile used to save and recognize
+// audio. The short profile produces denser fingerprints so that references
+// lasting only a few seconds are found reliably, and uses the monitor and
+// status thresholds of the short_reference section, tuned for finding short
+// content inside long recordings, see thresholds. References and queries must
+// use the same profile for their hashes to match, so songs fingerprinted with
+// another profile are reported and left out of recognition.
+//
+// Parameters:
+//   - name: PROFILE_DEFAULT, PROFILE_SHORT, or empty for the default profile.
+//
+// Returns:
+//   - An error if the profile does not exist or the songs of the main
+//     database could not be counted.
+func (e *Eureka) SetProfile(name string) error {
+	if name == "" {
+		name = PROFILE_DEFAULT
+	}
+	if _, err := ProfileByName(e.Config, name); err != nil {
+		return err
+	}
+
+	e.Config.Config.Profile = name
+	if err := e.checkProfile("the main database"); err != nil {
+		return err
+	}
+	for _, c := range e.catalogues {
+		c.eureka.Config.Config.Profile = name
+		if err := c.eureka.checkProfile("catalogue " + c.name); err != nil {
+			logger.Error(fmt.Errorf("error checking catalogue %s: %v", c.name, err))
+		}
+	}
+
+	if name == PROFILE_SHORT {
+		logger.Info("Using the short reference fingerprinting profile")
+	}
+
+	return nil
+}
+
+// checkProfile warns when songs of the database were fingerprinted with
+// another profile than the selected one, and restricts recognition to the
+// songs of the selected profile if so, see searchFilter
+func (e *Eureka) checkProfile(where string) error {
+	counts, err := e.database.GetProfileSongCounts()
+	if err != nil {
+		return fmt.Errorf("error counting songs by profile: %v", err)
+	}
+
+	other := 0
+	for profile, count := range counts {
+		if profile != e.Config.Config.Profile {
+			other += count
+		}
+	}
+	e.mixedProfiles = other > 0
+	if other > 0 {
+		logger.Warn(fmt.Sprintf("%d songs of %s were fingerprinted with another profile than %s and will not be recognized", other, where, e.Config.Config.Profile))
+	}
+
+	return nil
+}
+
+// thresholds holds the monitor and status settings in effect under the
+// selected profile
+type thresholds struct {
+	monitorWindowSeconds    float64
+	monitorHopSeconds       float64
+	monitorMinAlignedHashes int
+	statusMinAlignedHashes  int
+	statusMaxSeconds        float64
+}
+
+// thresholds returns the monitor and status settings of the configuration,
+// replaced by those set in the short_reference section under the short
+// profile. The monitor aligned hashes threshold, which the monitor and the
+// unknown query recording share, gets its default here, the defaults of the
+// other unset values are applied by their callers.
+func (e *Eureka) thresholds() thresholds {
+	t := thresholds{
+		monitorWindowSeconds:    e.Config.Monitor.WindowSeconds,
+		monitorHopSeconds:       e.Config.Monitor.HopSeconds,
+		monitorMinAlignedHashes: e.Config.Monitor.MinAlignedHashes,
+		statusMinAlignedHashes:  e.Config.Recognition.StatusMinAlignedHashes,
+		statusMaxSeconds:        e.Config.Recognition.StatusMaxSeconds,
+	}
+	if t.monitorMinAlignedHashes <= 0 {
+		t.monitorMinAlignedHashes = DEFAULT_MONITOR_MIN_ALIGNED_HASHES
+	}
+	if e.Config.Config.Profile != PROFILE_SHORT {
+		return t
+	}
+
+	short := e.Config.ShortReference
+	if short.MonitorWindowSeconds > 0 {
+		t.monitorWindowSeconds = short.MonitorWindowSeconds
+	}
+	if short.MonitorHopSeconds > 0 {
+		t.monitorHopSeconds = short.MonitorHopSeconds
+	}
+	if short.MonitorMinAlignedHashes > 0 {
+		t.monitorMinAlignedHashes = short.MonitorMinAlignedHashes
+	}
+	if short.StatusMinAlignedHashes > 0 {
+		t.statusMinAlignedHashes = short.StatusMinAlignedHashes
+	}
+	if short.StatusMaxSeconds > 0 {
+		t.statusMaxSeconds = short.StatusMaxSeconds
+	}
+
+	return t
+}
+
+// fingerprintProfile returns the peak picking and pairing settings of the
+// selected profile
+func (e *Eureka) fingerprintProfile() fingerprint.Profile {
+	profile, _ := ProfileByName(e.Config, e.Config.Config.Profile)
+	return profile
+}
+
+// ProfileByName returns the peak picking and pairing settings of a profile,
+// with the short profile overridden by the short_reference section of the
+// configuration.
+//
+// Parameters:
+//   - cfg: The configuration holding the short_reference overrides.
+//   - name: PROFILE_DEFAULT, PROFILE_SHORT, or empty for the default profile.
+//
+// Returns:
+//   - The settings of the profile.
+//   - An error if the profile does not exist.
+func ProfileByName(cfg config.Config, name string) (fingerprint.Profile, error) {
+	switch name {
+	case "", PROFILE_DEFAULT:
+		return fingerprint.DefaultProfile, nil
+	case PROFILE_SHORT:
+	default:
+		return fingerprint.DefaultProfile, fmt.Errorf("unknown fingerprinting profile %q, expected %s or %s", name, PROFILE_DEFAULT, PROFILE_SHORT)
+	}
+
+	profile := fingerprint.ShortProfile
+	short := cfg.ShortReference
+	if short.PeakThreshold > 0 {
+		profile.PeakThreshold = short.PeakThreshold
+	}
+	if short.FanValue > 0 {
+		profile.FanValue = short.FanValue
+	}
+	if short.MaxHashTimeDelta > 0 {
+		profile.MaxHashTimeDelta = short.MaxHashTimeDelta
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,543 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	DEFAULT_TOP_RESULTS = 1 // Number of results returned when recognition.top_results is not set
+	OFFSET_TOLERANCE_MS = 1 // Offsets are truncated to whole milliseconds, so aligned hashes may differ by this much
+)
+
+// Result represents a song matched against a query clip
+type Result struct {
+	Catalogue     string   `json:"catalogue,omitempty"` // Name of the catalogue the song was found in, empty unless catalogues are configured
+	SongID        int      `json:"song_id"`
+	SongName      string   `json:"song_name"`
+	Artist        string   `json:"artist"`
+	FileSHA1      string   `json:"file_sha1"`
+	AlignedHashes int      `json:"aligned_hashes"`           // Number of query hashes that agree on Offset
+	Score         float64  `json:"score"`                    // Aligned hashes weighted by rarity when IDF weighting is enabled, else AlignedHashes
+	Offset        int      `json:"offset_ms"`                // Position of the query start within the song, in milliseconds
+	OffsetSeconds float64  `json:"offset_seconds"`           // Offset expressed in seconds
+	SpeedFactor   float64  `json:"speed_factor"`             // Playback speed of the query relative to the song, 1 when unchanged
+	Semitones     float64  `json:"semitones"`                // Pitch of the query relative to the song, 0 when unchanged
+	Windows       int      `json:"windows,omitempty"`        // Number of sub-windows the query was split into, 0 unless voting
+	WindowsAgreed int      `json:"windows_agreed,omitempty"` // Number of sub-windows that agreed on the song and offset, 0 unless voting
+	Section       *Section `json:"section,omitempty"`        // Marked section of the song the query starts in, nil when the song has no marker there
+
+	// InputConfidence is the share of the query hashes that aligned with the
+	// song, it tells how much of the clip is explained by the match. When
+	// voting, it and the other counts are those of the strongest agreeing
+	// sub-window only.
+	InputConfidence float64 `json:"input_confidence"`
+	// FingerprintedConfidence is the share of the song's stored hashes that
+	// aligned with the query, it tells how much of the song was heard.
+	FingerprintedConfidence float64 `json:"fingerprinted_confidence"`
+}
+
+// alignment holds the best offset found for a single song
+type alignment struct {
+	SongID      int
+	Offset      int
+	Count       int
+	Score       float64
+	SpeedFactor float64
+	Semitones   float64
+}
+
+// Recognize identifies an audio clip against the songs stored in the database.
+// The clip goes through the same pipeline used by Save, its hashes are looked
+// up in the fingerprints table and the hits are aligned by offset difference.
+//
+// Parameters:
+//   - path: The path to the audio file to identify.
+//
+// Returns:
+//   - The best matching songs ordered by the number of aligned hashes, limited
+//     to recognition.top_results entries. When nothing matches and
+//     unknown.record is set, the clip is kept as an unknown query.
+//   - An error if the file could not be processed or the lookup failed.
+func (e *Eureka) Recognize(path string) ([]Result, error) {
+	results, _, err := e.recognizeFile(path)
+	return results, err
+}
+
+// RecognizeStatus identifies an audio clip like Recognize and also tells
+// whether the clip was long enough to decide, see Decide.
+//
+// Parameters:
+//   - path: The path to the audio file to identify.
+//
+// Returns:
+//   - The best matching songs ordered by the number of aligned hashes.
+//   - The decision for the clip.
+//   - An error if the file could not be processed or the lookup failed.
+func (e *Eureka) RecognizeStatus(path string) ([]Result, Decision, error) {
+	candidates, seconds, err := e.withStatusCandidates().recognizeFile(path)
+	if err != nil {
+		return nil, Decision{}, err
+	}
+
+	return limitResults(candidates, e.topResults()), e.Decide(candidates, seconds), nil
+}
+
+// recognizeFile identifies an audio file and returns its length in seconds
+func (e *Eureka) recognizeFile(path string) ([]Result, float64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error stating path: %v", err)
+	}
+
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("path is a directory not supported, expected a file")
+	}
+
+	logger.Info(fmt.Sprintf("Recognizing audio file: %s", filepath.Base(path)))
+
+	samples, sampleRate, err := loadSamples(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results, err := e.RecognizeSamples(samples, sampleRate)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if e.unmatched(results) {
+		if err := e.recordUnknownSamples(samples, sampleRate, path, 0); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return results, float64(len(samples)) / float64(sampleRate), nil
+}
+
+// RecognizeSamples identifies decoded mono samples against the songs stored in
+// the database without going through the filesystem.
+//
+// Parameters:
+//   - samples: Mono audio samples scaled to the range [-1, 1].
+//   - sampleRate: The sample rate of the audio.
+//
+// Returns:
+//   - The best matching songs ordered by the number of aligned hashes.
+//   - An error if the samples could not be fingerprinted or the lookup failed.
+func (e *Eureka) RecognizeSamples(samples []float64, sampleRate int) ([]Result, error) {
+	if e.Config.Recognition.Voting {
+		return e.recognizeVoting(samples, sampleRate)
+	}
+
+	return e.recognizeSamples(samples, sampleRate)
+}
+
+// RecognizeFingerprints identifies a query from fingerprints computed
+// elsewhere, for example by a client that sends hashes and offsets instead of
+// audio. Only the matching stage runs, so the tempo, pitch, jitter and voting
+// options, which need the query audio, do not apply.
+//
+// Parameters:
+//   - fingerprints: The hashes and offsets of the query, SongID is ignored.
+//   - profile: The name of the profile the fingerprints were made with.
+//
+// Returns:
+//   - The best matching songs ordered by score.
+//   - An error if the profile is not the selected one or the lookup failed.
+func (e *Eureka) RecognizeFingerprints(fingerprints []fingerprint.Fingerprint, profile string) ([]Result, error) {
+	if profile != e.Config.Config.Profile {
+		return nil, fmt.Errorf("fingerprints were made with the %s profile, the catalogue is searched with the %s profile", profile, e.Config.Config.Profile)
+	}
+
+	logger.Info(fmt.Sprintf("Recognizing %d precomputed fingerprints", len(fingerprints)))
+	results, err := e.match(fingerprints, len(fingerprints))
+	if err != nil {
+		return nil, err
+	}
+
+	if e.unmatched(results) {
+		if err := e.recordUnknown(fingerprints, "fingerprints", 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// FingerprintFile runs an audio file through the same pipeline used for
+// queries and returns its fingerprints, in the form accepted by
+// RecognizeFingerprints. The profile must be the one the catalogue the
+// fingerprints are meant for was saved with.
+func FingerprintFile(path string, profile fingerprint.Profile) ([]fingerprint.Fingerprint, error) {
+	samples, sampleRate, err := loadSamples(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fingerprint.FingerprintSamples(samples, sampleRate, profile)
+}
+
+// recognizeSamples identifies samples as a single query
+func (e *Eureka) recognizeSamples(samples []float64, sampleRate int) ([]Result, error) {
+	peaks, err := fingerprint.SamplesToPeaks(samples, sampleRate, e.fingerprintProfile())
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Config.Recognition.TempoSearch || e.Config.Recognition.PitchSearch {
+		return e.matchVariants(peaks, e.searchVariants())
+	}
+
+	fingerprints := e.queryFingerprints(peaks)
+	logger.Info(fmt.Sprintf("Generated %d query fingerprints", len(fingerprints)))
+
+	return e.match(fingerprints, len(fingerprint.GenerateFingerprints(peaks, e.fingerprintProfile())))
+}
+
+// queryFingerprints hashes query peaks, expanding every pair to its
+// neighbouring bins and time deltas when recognition.jitter_freq_bins or
+// recognition.jitter_time_frames are set. Radii above the maximum supported
+// by fingerprint.ExpandFingerprints are reported and capped.
+func (e *Eureka) queryFingerprints(peaks []fingerprint.Peak) []fingerprint.Fingerprint {
+	freqRadius := e.Config.Recognition.JitterFreqBins
+	if freqRadius > fingerprint.MAX_JITTER_FREQ_BINS {
+		logger.Warn(fmt.Sprintf("recognition.jitter_freq_bins is %d, capped at %d", freqRadius, fingerprint.MAX_JITTER_FREQ_BINS))
+		freqRadius = fingerprint.MAX_JITTER_FREQ_BINS
+	}
+
+	timeRadius := e.Config.Recognition.JitterTimeFrames
+	if timeRadius > fingerprint.MAX_JITTER_TIME_FRAMES {
+		logger.Warn(fmt.Sprintf("recognition.jitter_time_frames is %d, capped at %d", timeRadius, fingerprint.MAX_JITTER_TIME_FRAMES))
+		timeRadius = fingerprint.MAX_JITTER_TIME_FRAMES
+	}
+
+	return fingerprint.ExpandFingerprints(peaks, e.fingerprintProfile(), freqRadius, timeRadius)
+}
+
+// loadSamples converts an audio file to WAV in a temporary location and
+// returns its mono samples and sample rate.
+func loadSamples(path string) ([]float64, int, error) {
+	tmp, err := os.CreateTemp("", "eureka-*.wav")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating temporary file: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	filePath, err := fingerprint.ConvertToWAV(path, tmp.Name())
+	if err != nil {
+		return nil, 0, fmt.Errorf("error converting to WAV: %v", err)
+	}
+
+	wavInfo, err := fingerprint.ReadWavInfo(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading WAV info: %v", err)
+	}
+
+	return wavInfo.Samples, wavInfo.SampleRate, nil
+}
+
+// offsetPeaks shifts peaks found in a chunk of audio to their absolute
+// position, given the number of spectrogram frames that preceded the chunk
+func offsetPeaks(peaks []fingerprint.Peak, frames int, sampleRate int) []fingerprint.Peak {
+	startMS := float64(frames) * float64(fingerprint.WINDOW_SIZE) / float64(sampleRate) * 1000
+	for i := range peaks {
+		peaks[i].Time += float64(frames)
+		peaks[i].TimeMS += startMS
+	}
+	return peaks
+}
+
+// fingerprintChunked fingerprints a long recording in frame aligned chunks.
+// The spectrogram stage windows the whole signal it is given, which would fade
+// out the start and end of a long recording, so each chunk is processed on
+// its own and its peaks are shifted back to their absolute position.
+func fingerprintChunked(samples []float64, sampleRate int, chunkSeconds float64, profile fingerprint.Profile) ([]fingerprint.Fingerprint, error) {
+	chunk := int(chunkSeconds*float64(sampleRate)) / fingerprint.WINDOW_SIZE * fingerprint.WINDOW_SIZE
+	if chunk < fingerprint.WINDOW_SIZE {
+		chunk = fingerprint.WINDOW_SIZE
+	}
+
+	var fingerprints []fingerprint.Fingerprint
+	for start := 0; start < len(samples); start += chunk {
+		end := start + chunk
+		if end > len(samples) {
+			end = len(samples)
+		}
+
+		peaks, err := fingerprint.SamplesToPeaks(samples[start:end], sampleRate, profile)
+		if err != nil {
+			return nil, err
+		}
+		peaks = offsetPeaks(peaks, start/fingerprint.WINDOW_SIZE, sampleRate)
+		fingerprints = append(fingerprints, fingerprint.GenerateFingerprints(peaks, profile)...)
+	}
+
+	return fingerprints, nil
+}
+
+// match looks up query fingerprints in the database, or in every catalogue
+// when catalogues are configured, aligns the hits and returns the top results
+// with their song metadata. Confidences are measured against queryHashes.
+// Results of identical queries are served from the cache, results missing a
+// catalogue that failed or timed out are not cached.
+func (e *Eureka) match(fingerprints []fingerprint.Fingerprint, queryHashes int) ([]Result, error) {
+	if len(fingerprints) == 0 {
+		return nil, nil
+	}
+
+	generation := e.cache.current()
+	key := e.queryKey(fingerprints, queryHashes)
+	if results, ok := e.cache.get(key); ok {
+		logger.Info("Using cached results for an identical query")
+		return results, nil
+	}
+
+	results, complete, err := e.federate(func(ctx context.Context, c *Eureka) ([]Result, error) {
+		return c.matchCatalogue(ctx, fingerprints, queryHashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if complete {
+		e.cache.put(key, generation, results)
+	}
+
+	return results, nil
+}
+
+// matchCatalogue looks up query fingerprints in a single database
+func (e *Eureka) matchCatalogue(ctx context.Context, fingerprints []fingerprint.Fingerprint, queryHashes int) ([]Result, error) {
+	matches, weights, err := e.lookup(ctx, uniqueHashes(fingerprints))
+	if err != nil {
+		return nil, err
+	}
+
+	return e.results(ctx, alignMatches(fingerprints, matches, weights), queryHashes)
+}
+
+// rank aligns database hits against the query fingerprints and turns the best
+// alignments into results with their song metadata.
+func (e *Eureka) rank(ctx context.Context, fingerprints []fingerprint.Fingerprint, matches []fingerprint.Fingerprint, weights map[string]float64) ([]Result, error) {
+	return e.results(ctx, alignMatches(fingerprints, matches, weights), len(fingerprints))
+}
+
+// results keeps the top alignments and turns them into results with their
+// song metadata.
+//
+// Parameters:
+//   - ctx: Cancels the database queries, see queryCatalogues.
+//   - alignments: The alignments ordered by descending count.
+//   - queryHashes: The number of hashes extracted from the query.
+//
+// Returns:
+//   - At most recognition.top_results results.
+//   - An error if the metadata of a song could not be loaded.
+func (e *Eureka) results(ctx context.Context, alignments []alignment, queryHashes int) ([]Result, error) {
+	if topResults := e.topResults(); len(alignments) > topResults {
+		alignments = alignments[:topResults]
+	}
+
+	songIDs := make([]int, len(alignments))
+	for i, a := range alignments {
+		songIDs[i] = a.SongID
+	}
+	songs, err := e.database.GetSongsByIDs(ctx, songIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading matched songs: %v", err)
+	}
+
+	results := make([]Result, 0, len(alignments))
+	for _, a := range alignments {
+		song, ok := songs[a.SongID]
+		if !ok {
+			return nil, fmt.Errorf("error loading matched song: song with ID %d not found", a.SongID)
+		}
+		results = append(results, Result{
+			SongID:                  song.ID,
+			SongName:                song.Name,
+			Artist:                  song.Artist,
+			FileSHA1:                song.FileSHA1,
+			AlignedHashes:           a.Count,
+			Score:                   a.Score,
+			Offset:                  a.Offset,
+			OffsetSeconds:           float64(a.Offset) / 1000,
+			SpeedFactor:             a.SpeedFactor,
+			Semitones:               a.Semitones,
+			InputConfidence:         confidence(a.Count, queryHashes),
+			FingerprintedConfidence: confidence(a.Count, song.TotalHashes),
+		})
+	}
+
+	if err := e.setSections(ctx, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// topResults returns the number of results a recognition keeps
+func (e *Eureka) topResults() int {
+	if topResults := e.Config.Recognition.TopResults; topResults > 0 {
+		return topResults
+	}
+	return DEFAULT_TOP_RESULTS
+}
+
+// limitResults keeps the first topResults results
+func limitResults(results []Result, topResults int) []Result {
+	if len(results) > topResults {
+		return results[:topResults]
+	}
+	return results
+}
+
+// confidence returns the ratio of aligned hashes to total hashes, capped at 1
+func confidence(aligned, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Min(float64(aligned)/float64(total), 1)
+}
+
+// uniqueHashes returns the distinct hashes of the given fingerprints
+func uniqueHashes(fingerprints []fingerprint.Fingerprint) []string {
+	seen := make(map[string]bool, len(fingerprints))
+	hashes := make([]string, 0, len(fingerprints))
+	for _, fp := range fingerprints {
+		if !seen[fp.Hash] {
+			seen[fp.Hash] = true
+			hashes = append(hashes, fp.Hash)
+		}
+	}
+	return hashes
+}
+
+// alignMatches builds, for every song, a histogram of the difference between
+// the stored offset and the query offset of each shared hash. A true match
+// produces many hashes with the same difference, while random hits spread
+// across the histogram. Neighbouring bins within OFFSET_TOLERANCE_MS are
+// counted together to absorb the millisecond truncation of the offsets.
+// The jitter expansions of a query pair count once per song and offset, so
+// expanding the query does not inflate the counts of true matches.
+//
+// Parameters:
+//   - query: The fingerprints extracted from the query clip.
+//   - matches: The stored fingerprints sharing a hash with the query.
+//   - weights: The score of each hash, nil to score every hash 1.
+//
+// Returns:
+//   - One alignment per song, ordered by descending score.
+func alignMatches(query []fingerprint.Fingerprint, matches []fingerprint.Fingerprint, weights map[string]float64) []alignment {
+	return alignHistograms(offsetHistograms(query, matches, weights))
+}
+
+// alignHistograms picks the strongest offset difference of every song's
+// histogram, see alignMatches
+func alignHistograms(histograms map[int]offsetHistogram) []alignment {
+	alignments := make([]alignment, 0, len(histograms))
+	for songID, histogram := range histograms {
+		best := alignment{SongID: songID, SpeedFactor: 1}
+		for diff := range histogram {
+			count, score := histogram.around(diff)
+			if score > best.Score || (score == best.Score && diff < best.Offset) {
+				best.Count = count
+				best.Score = score
+				best.Offset = diff
+			}
+		}
+		alignments = append(alignments, best)
+	}
+
+	sortAlignments(alignments)
+
+	return alignments
+}
+
+// offsetBin counts the hashes sharing an offset difference
+type offsetBin struct {
+	count int
+	score float64
+}
+
+// offsetHistogram maps offset differences, in milliseconds, to their bins
+type offsetHistogram map[int]*offsetBin
+
+// around returns the count and score of the bins within OFFSET_TOLERANCE_MS
+// of diff
+func (h offsetHistogram) around(diff int) (int, float64) {
+	count, score := 0, 0.0
+	for d := diff - OFFSET_TOLERANCE_MS; d <= diff+OFFSET_TOLERANCE_MS; d++ {
+		if b := h[d]; b != nil {
+			count += b.count
+			score += b.score
+		}
+	}
+	return count, score
+}
+
+// pairHit identifies the hit of a query pair on a song at an offset
+type pairHit struct {
+	songID int
+	diff   int
+	offset int
+	pair   int
+}
+
+// offsetHistograms builds the offset difference histogram of every song
+// sharing a hash with the query, see alignMatches
+func offsetHistograms(query []fingerprint.Fingerprint, matches []fingerprint.Fingerprint, weights map[string]float64) map[int]offsetHistogram {
+	queryHashes := make(map[string][]fingerprint.Fingerprint)
+	for _, fp := range query {
+		queryHashes[fp.Hash] = append(queryHashes[fp.Hash], fp)
+	}
+
+	histograms := make(map[int]offsetHistogram)
+	seen := make(map[pairHit]bool)
+	for _, m := range matches {
+		weight := 1.0
+		if weights != nil {
+			weight = weights[m.Hash]
+		}
+		for _, fp := range queryHashes[m.Hash] {
+			offset := fp.Offset
+			if fp.Pair != 0 {
+				hit := pairHit{songID: m.SongID, diff: m.Offset - offset, offset: offset, pair: fp.Pair}
+				if seen[hit] {
+					continue
+				}
+				seen[hit] = true
+			}
+			if histograms[m.SongID] == nil {
+				histograms[m.SongID] = make(offsetHistogram)
+			}
+			b := histograms[m.SongID][m.Offset-offset]
+			if b == nil {
+				b = &offsetBin{}
+				histograms[m.SongID][m.Offset-offset] = b
+			}
+			b.count++
+			b.score += weight
+		}
+	}
+
+	return histograms
+}
+
+// sortAlignments orders alignments by descending score, then by song ID
+func sortAlignments(alignments []alignment) {
+	sort.Slice(alignments, func(i, j int) bool {
+		if alignments[i].Score != alignments[j].Score {
+			return alignments[i].Score > alignments[j].Score
+		}
+		return alignments[i].SongID < alignments[j].SongID
+	})
+}
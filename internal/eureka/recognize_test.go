@@ -0,0 +1,96 @@
+package eureka
+
+import (
+	"testing"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+)
+
+// testFingerprint builds a fingerprint of the given song
+func testFingerprint(hash string, offset int, songID int) fingerprint.Fingerprint {
+	return fingerprint.Fingerprint{Hash: hash, Offset: offset, SongID: songID}
+}
+
+func TestAlignMatches(t *testing.T) {
+	query := []fingerprint.Fingerprint{
+		testFingerprint("a", 0, 0),
+		testFingerprint("b", 100, 0),
+		testFingerprint("c", 200, 0),
+	}
+
+	tests := []struct {
+		name    string
+		query   []fingerprint.Fingerprint
+		matches []fingerprint.Fingerprint
+		weights map[string]float64
+		want    []alignment // Only SongID, Offset and Count are compared
+	}{
+		{
+			name:    "single song",
+			query:   query,
+			matches: []fingerprint.Fingerprint{testFingerprint("a", 1000, 1), testFingerprint("b", 1100, 1), testFingerprint("c", 1200, 1)},
+			want:    []alignment{{SongID: 1, Offset: 1000, Count: 3}},
+		},
+		{
+			name:  "random hits rank below the aligned song",
+			query: query,
+			matches: []fingerprint.Fingerprint{
+				testFingerprint("a", 500, 2), testFingerprint("b", 900, 2),
+				testFingerprint("a", 1000, 1), testFingerprint("b", 1100, 1), testFingerprint("c", 1200, 1),
+			},
+			want: []alignment{{SongID: 1, Offset: 1000, Count: 3}, {SongID: 2, Offset: 500, Count: 1}},
+		},
+		{
+			name:    "neighbouring bins counted together",
+			query:   query,
+			matches: []fingerprint.Fingerprint{testFingerprint("a", 1000, 1), testFingerprint("b", 1101, 1), testFingerprint("c", 1199, 1)},
+			want:    []alignment{{SongID: 1, Offset: 1000, Count: 3}},
+		},
+		{
+			name: "expansions of a pair count once",
+			query: []fingerprint.Fingerprint{
+				{Hash: "a", Offset: 0, Pair: 1},
+				{Hash: "a2", Offset: 0, Pair: 1},
+				{Hash: "b", Offset: 100, Pair: 2},
+			},
+			matches: []fingerprint.Fingerprint{testFingerprint("a", 1000, 1), testFingerprint("a2", 1000, 1), testFingerprint("b", 1100, 1)},
+			want:    []alignment{{SongID: 1, Offset: 1000, Count: 2}},
+		},
+		{
+			name:    "unexpanded hashes all count",
+			query:   []fingerprint.Fingerprint{testFingerprint("a", 0, 0), testFingerprint("a2", 0, 0)},
+			matches: []fingerprint.Fingerprint{testFingerprint("a", 1000, 1), testFingerprint("a2", 1000, 1)},
+			want:    []alignment{{SongID: 1, Offset: 1000, Count: 2}},
+		},
+		{
+			name:  "weights rank rare hashes first",
+			query: query,
+			matches: []fingerprint.Fingerprint{
+				testFingerprint("a", 1000, 1), testFingerprint("b", 1100, 1),
+				testFingerprint("c", 3000, 2),
+			},
+			weights: map[string]float64{"a": 0.1, "b": 0.1, "c": 2},
+			want:    []alignment{{SongID: 2, Offset: 2800, Count: 1}, {SongID: 1, Offset: 1000, Count: 2}},
+		},
+		{
+			name:  "no matches",
+			query: query,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alignments := alignMatches(tt.query, tt.matches, tt.weights)
+			if len(alignments) != len(tt.want) {
+				t.Fatalf("got %d alignments, want %d: %+v", len(alignments), len(tt.want), alignments)
+			}
+			for i, a := range alignments {
+				want := tt.want[i]
+				if a.SongID != want.SongID || a.Offset != want.Offset || a.Count != want.Count {
+					t.Errorf("alignment %d is song %d at %dms with %d hashes, want song %d at %dms with %d hashes",
+						i, a.SongID, a.Offset, a.Count, want.SongID, want.Offset, want.Count)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package eureka
+
+import (
+	"fmt"
+	"sort"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	REPEAT_CHUNK_SECONDS        = 30.0 // Length of the chunks a song is fingerprinted in before matching it against itself
+	REPEAT_MIN_SECONDS          = 3.0  // Shortest repeated section reported
+	REPEAT_MAX_GAP_MS           = 1500 // Longest stretch without an aligned hash inside a repeated section
+	REPEAT_LAG_TOLERANCE_MS     = 30   // Max difference between lags counted as the same repetition, about one spectrogram frame
+	REPEAT_MIN_HASHES           = 20   // Min number of aligned hashes for a pair of sections to count as a repetition
+	REPEAT_MAX_HASH_OCCURRENCES = 50   // Hashes occurring more often in a song carry no position information and are skipped
+	REPEAT_MIN_OVERLAP          = 0.5  // Min overlap, relative to the shorter section, for two sections to be the same occurrence
+)
+
+// Occurrence is one place in a song where a repeated section is heard
+type Occurrence struct {
+	Start float64 // Start of the occurrence, in seconds
+	End   float64 // End of the occurrence, in seconds
+}
+
+// RepeatedSection is a part of a song heard several times, like a chorus or a loop
+type RepeatedSection struct {
+	Occurrences   []Occurrence // Every occurrence of the section in chronological order
+	AlignedHashes int          // Number of self-matching hashes supporting the section
+}
+
+// Duration returns the mean length of the occurrences, in seconds
+func (s RepeatedSection) Duration() float64 {
+	if len(s.Occurrences) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, o := range s.Occurrences {
+		total += o.End - o.Start
+	}
+	return total / float64(len(s.Occurrences))
+}
+
+// FindRepetitionsFile fingerprints an audio file with the given profile and
+// finds the sections repeated within it, see FindRepetitions.
+func FindRepetitionsFile(path string, profile fingerprint.Profile) ([]RepeatedSection, error) {
+	samples, sampleRate, err := loadSamples(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints, err := fingerprintChunked(samples, sampleRate, REPEAT_CHUNK_SECONDS, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error fingerprinting %s: %v", path, err)
+	}
+
+	return FindRepetitions(fingerprints), nil
+}
+
+// FindRepetitions matches the fingerprints of a song against themselves to
+// find the sections it repeats. Every pair of equal hashes at different
+// offsets votes for the lag between them, a repeated section shows up as many
+// votes for the same lag over a continuous stretch of the song. The pairs of
+// sections found this way are then grouped into sections with all their
+// occurrences.
+//
+// Parameters:
+//   - fingerprints: The fingerprints of a single song.
+//
+// Returns:
+//   - The repeated sections, the ones heard most often first.
+func FindRepetitions(fingerprints []fingerprint.Fingerprint) []RepeatedSection {
+	pairs := repeatedPairs(fingerprints)
+	sections := groupRepetitions(pairs)
+	logger.Info(fmt.Sprintf("Found %d repeated sections from %d matching pairs", len(sections), len(pairs)))
+
+	return sections
+}
+
+// repeatPair is a section of a song heard again Lag milliseconds later
+type repeatPair struct {
+	Start int // Start of the first occurrence, in milliseconds
+	End   int // End of the first occurrence, in milliseconds
+	Lag   int // Distance to the second occurrence, in milliseconds
+	Count int // Number of aligned hashes
+}
+
+// repeatedPairs finds the pairs of sections that share aligned hashes
+func repeatedPairs(fingerprints []fingerprint.Fingerprint) []repeatPair {
+	offsets := make(map[string][]int)
+	for _, fp := range fingerprints {
+		offsets[fp.Hash] = append(offsets[fp.Hash], fp.Offset)
+	}
+
+	// Positions of the first occurrence of every hash pair, by lag
+	minLag := int(REPEAT_MIN_SECONDS * 1000)
+	positions := make(map[int][]int)
+	for _, list := range offsets {
+		if len(list) < 2 || len(list) > REPEAT_MAX_HASH_OCCURRENCES {
+			continue
+		}
+		sort.Ints(list)
+		for i := range list {
+			for j := i + 1; j < len(list); j++ {
+				if lag := list[j] - list[i]; lag >= minLag {
+					positions[lag] = append(positions[lag], list[i])
+				}
+			}
+		}
+	}
+
+	var pairs []repeatPair
+	for _, pair := range lagRuns(positions, REPEAT_MIN_HASHES, minLag) {
+		// Copies that overlap each other are described by a longer lag
+		if pair.End-pair.Start <= pair.Lag {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
+}
+
+// lagRuns merges the lags around each peak of a lag histogram, then splits the
+// votes for every merged lag into continuous runs.
+//
+// Parameters:
+//   - positions: The positions of the votes, in milliseconds, by lag.
+//   - minHashes: The min number of votes in a run.
+//   - minLength: The min length of a run, in milliseconds.
+//
+// Returns:
+//   - The runs, strongest lag first.
+func lagRuns(positions map[int][]int, minHashes int, minLength int) []repeatPair {
+	lags := make([]int, 0, len(positions))
+	for lag := range positions {
+		lags = append(lags, lag)
+	}
+	sort.Slice(lags, func(i, j int) bool {
+		if len(positions[lags[i]]) != len(positions[lags[j]]) {
+			return len(positions[lags[i]]) > len(positions[lags[j]])
+		}
+		return lags[i] < lags[j]
+	})
+
+	var runs []repeatPair
+	used := make(map[int]bool)
+	for _, lag := range lags {
+		if used[lag] {
+			continue
+		}
+
+		var times []int
+		for l := lag - REPEAT_LAG_TOLERANCE_MS; l <= lag+REPEAT_LAG_TOLERANCE_MS; l++ {
+			if !used[l] {
+				times = append(times, positions[l]...)
+			}
+		}
+		if len(times) < minHashes {
+			continue
+		}
+		for l := lag - REPEAT_LAG_TOLERANCE_MS; l <= lag+REPEAT_LAG_TOLERANCE_MS; l++ {
+			used[l] = true
+		}
+
+		sort.Ints(times)
+		for _, run := range splitRuns(times) {
+			if len(run) >= minHashes && run[len(run)-1]-run[0] >= minLength {
+				runs = append(runs, repeatPair{Start: run[0], End: run[len(run)-1], Lag: lag, Count: len(run)})
+			}
+		}
+	}
+
+	return runs
+}
+
+// splitRuns splits sorted positions wherever two consecutive ones are more
+// than REPEAT_MAX_GAP_MS apart
+func splitRuns(times []int) [][]int {
+	var runs [][]int
+	start := 0
+	for i := 1; i <= len(times); i++ {
+		if i == len(times) || times[i]-times[i-1] > REPEAT_MAX_GAP_MS {
+			runs = append(runs, times[start:i])
+			start = i
+		}
+	}
+	return runs
+}
+
+// groupRepetitions links the sections of every pair and the sections that
+// overlap each other, then merges the sections of each group into occurrences.
+func groupRepetitions(pairs []repeatPair) []RepeatedSection {
+	type section struct {
+		start, end int
+		pair       int
+	}
+
+	sections := make([]section, 0, 2*len(pairs))
+	for i, p := range pairs {
+		sections = append(sections,
+			section{start: p.Start, end: p.End, pair: i},
+			section{start: p.Start + p.Lag, end: p.End + p.Lag, pair: i})
+	}
+
+	parent := make([]int, len(sections))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range sections {
+		for j := i + 1; j < len(sections); j++ {
+			if sections[i].pair == sections[j].pair ||
+				overlapRatio(sections[i].start, sections[i].end, sections[j].start, sections[j].end) >= REPEAT_MIN_OVERLAP {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := make(map[int][]section)
+	for i, s := range sections {
+		groups[find(i)] = append(groups[find(i)], s)
+	}
+
+	result := make([]RepeatedSection, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return group[i].start < group[j].start })
+
+		var repeated RepeatedSection
+		counted := make(map[int]bool)
+		var current *Occurrence
+		currentEnd := 0
+		for _, s := range group {
+			if !counted[s.pair] {
+				counted[s.pair] = true
+				repeated.AlignedHashes += pairs[s.pair].Count
+			}
+			if current != nil && s.start < currentEnd {
+				if s.end > currentEnd {
+					currentEnd = s.end
+					current.End = float64(currentEnd) / 1000
+				}
+				continue
+			}
+			repeated.Occurrences = append(repeated.Occurrences, Occurrence{
+				Start: float64(s.start) / 1000,
+				End:   float64(s.end) / 1000,
+			})
+			current = &repeated.Occurrences[len(repeated.Occurrences)-1]
+			currentEnd = s.end
+		}
+		result = append(result, repeated)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Occurrences) != len(result[j].Occurrences) {
+			return len(result[i].Occurrences) > len(result[j].Occurrences)
+		}
+		if result[i].AlignedHashes != result[j].AlignedHashes {
+			return result[i].AlignedHashes > result[j].AlignedHashes
+		}
+		return result[i].Occurrences[0].Start < result[j].Occurrences[0].Start
+	})
+
+	return result
+}
+
+// overlapRatio returns the length of the overlap of two intervals relative to
+// the shorter one
+func overlapRatio(startA, endA, startB, endB int) float64 {
+	overlap := min(endA, endB) - max(startA, startB)
+	shorter := min(endA-startA, endB-startB)
+	if overlap <= 0 || shorter <= 0 {
+		return 0
+	}
+	return float64(overlap) / float64(shorter)
+}
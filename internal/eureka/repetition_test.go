@@ -0,0 +1,82 @@
+package eureka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLagRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions map[int][]int
+		minHashes int
+		minLength int
+		want      []repeatPair
+	}{
+		{
+			name:      "single run",
+			positions: map[int][]int{5000: {0, 500, 1000, 1500, 2000}},
+			minHashes: 3,
+			minLength: 1000,
+			want:      []repeatPair{{Start: 0, End: 2000, Lag: 5000, Count: 5}},
+		},
+		{
+			name:      "neighbouring lags merged",
+			positions: map[int][]int{5000: {0, 1000, 2000}, 5000 + REPEAT_LAG_TOLERANCE_MS: {500, 1500}},
+			minHashes: 3,
+			minLength: 1000,
+			want:      []repeatPair{{Start: 0, End: 2000, Lag: 5000, Count: 5}},
+		},
+		{
+			name:      "gap splits runs",
+			positions: map[int][]int{5000: {0, 500, 1000, 1000 + REPEAT_MAX_GAP_MS + 1, 3000, 4000}},
+			minHashes: 3,
+			minLength: 1000,
+			want: []repeatPair{
+				{Start: 0, End: 1000, Lag: 5000, Count: 3},
+				{Start: 1000 + REPEAT_MAX_GAP_MS + 1, End: 4000, Lag: 5000, Count: 3},
+			},
+		},
+		{
+			name:      "too few hashes",
+			positions: map[int][]int{5000: {0, 500, 1000}},
+			minHashes: 4,
+			minLength: 0,
+		},
+		{
+			name:      "too short",
+			positions: map[int][]int{5000: {0, 500, 1000}},
+			minHashes: 3,
+			minLength: 3000,
+		},
+		{
+			name:      "strongest lag first",
+			positions: map[int][]int{5000: {0, 500, 1000}, 9000: {0, 500, 1000, 1500}},
+			minHashes: 3,
+			minLength: 1000,
+			want: []repeatPair{
+				{Start: 0, End: 1500, Lag: 9000, Count: 4},
+				{Start: 0, End: 1000, Lag: 5000, Count: 3},
+			},
+		},
+		{
+			name:      "ties ordered by lag",
+			positions: map[int][]int{9000: {0, 500, 1000}, 5000: {0, 500, 1000}},
+			minHashes: 3,
+			minLength: 1000,
+			want: []repeatPair{
+				{Start: 0, End: 1000, Lag: 5000, Count: 3},
+				{Start: 0, End: 1000, Lag: 9000, Count: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lagRuns(tt.positions, tt.minHashes, tt.minLength)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
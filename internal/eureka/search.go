@@ -0,0 +1,159 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	SEARCH_MAX_VARIANTS = 50 // Max number of speed and pitch combinations tried before the axes are searched separately
+)
+
+// variant describes one transformation of the query tried during a search
+type variant struct {
+	SpeedFactor float64 // Playback speed of the query relative to the song
+	Semitones   float64 // Pitch of the query relative to the song
+}
+
+// searchVariants returns the query transformations enabled in the config.
+// Tempo and pitch searches combine, so enabling both tries every pair, unless
+// that makes more than SEARCH_MAX_VARIANTS variants. Each variant is aligned
+// against every match on its own, so the speeds are then tried at the
+// original pitch and the shifts at the original speed, plus every shift at the
+// speed that resampling would give it, since sped up or slowed down playback
+// changes both at once. Other queries changed along both axes are missed.
+func (e *Eureka) searchVariants() []variant {
+	factors := []float64{1}
+	if e.Config.Recognition.TempoSearch {
+		factors = tempoFactors(e.Config.Recognition.TempoMaxChange, e.Config.Recognition.TempoStep)
+	}
+
+	shifts := []float64{0}
+	if e.Config.Recognition.PitchSearch {
+		shifts = pitchShifts(e.Config.Recognition.PitchMaxSemitones, e.Config.Recognition.PitchStepSemitones)
+	}
+
+	if len(factors)*len(shifts) > SEARCH_MAX_VARIANTS {
+		logger.Warn(fmt.Sprintf("Searching %d speeds and %d pitch shifts separately, as their %d combinations exceed %d",
+			len(factors), len(shifts), len(factors)*len(shifts), SEARCH_MAX_VARIANTS))
+
+		variants := make([]variant, 0, len(factors)+2*len(shifts))
+		for _, factor := range factors {
+			variants = append(variants, variant{SpeedFactor: factor})
+		}
+		for _, shift := range shifts {
+			if shift != 0 {
+				variants = append(variants, variant{SpeedFactor: 1, Semitones: shift})
+				variants = append(variants, variant{SpeedFactor: math.Pow(2, shift/12), Semitones: shift})
+			}
+		}
+		return variants
+	}
+
+	variants := make([]variant, 0, len(factors)*len(shifts))
+	for _, factor := range factors {
+		for _, shift := range shifts {
+			variants = append(variants, variant{SpeedFactor: factor, Semitones: shift})
+		}
+	}
+
+	return variants
+}
+
+// matchVariants recognizes query peaks while searching over playback speeds
+// and pitch shifts, so that sped up, slowed down or transposed audio still
+// matches. The query peaks are brought back to the song's speed and pitch for
+// each variant before hashing, all the resulting hashes are looked up at once
+// and every song keeps the variant with the best score.
+//
+// Parameters:
+//   - peaks: The peaks extracted from the query.
+//   - variants: The transformations to try.
+//
+// Returns:
+//   - The best matching songs, each with its estimated speed factor and pitch shift.
+//   - An error if the lookup failed.
+func (e *Eureka) matchVariants(peaks []fingerprint.Peak, variants []variant) ([]Result, error) {
+	queries := make([][]fingerprint.Fingerprint, len(variants))
+	var all []fingerprint.Fingerprint
+	for i, v := range variants {
+		transformed := fingerprint.ShiftPeaks(fingerprint.StretchPeaks(peaks, v.SpeedFactor), -v.Semitones)
+		queries[i] = e.queryFingerprints(transformed)
+		all = append(all, queries[i]...)
+	}
+	queryHashes := len(fingerprint.GenerateFingerprints(peaks, e.fingerprintProfile()))
+	logger.Info(fmt.Sprintf("Generated %d query fingerprints over %d search variants", len(all), len(variants)))
+
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	generation := e.cache.current()
+	key := e.queryKey(all, queryHashes)
+	if results, ok := e.cache.get(key); ok {
+		logger.Info("Using cached results for an identical query")
+		return results, nil
+	}
+
+	results, complete, err := e.federate(func(ctx context.Context, c *Eureka) ([]Result, error) {
+		return c.matchVariantsCatalogue(ctx, queries, variants, all, queryHashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if complete {
+		e.cache.put(key, generation, results)
+	}
+
+	return results, nil
+}
+
+// matchVariantsCatalogue looks up the fingerprints of every variant in a
+// single database, keeping the variant with the best score for each song
+func (e *Eureka) matchVariantsCatalogue(ctx context.Context, queries [][]fingerprint.Fingerprint, variants []variant,
+	all []fingerprint.Fingerprint, queryHashes int) ([]Result, error) {
+	matches, weights, err := e.lookup(ctx, uniqueHashes(all))
+	if err != nil {
+		return nil, err
+	}
+
+	best := make(map[int]alignment)
+	for i, v := range variants {
+		for _, a := range alignMatches(queries[i], matches, weights) {
+			a.SpeedFactor = v.SpeedFactor
+			a.Semitones = v.Semitones
+			current, ok := best[a.SongID]
+			if !ok || a.Score > current.Score || (a.Score == current.Score && v.distance() < current.variant().distance()) {
+				best[a.SongID] = a
+			}
+		}
+	}
+
+	alignments := make([]alignment, 0, len(best))
+	for _, a := range best {
+		alignments = append(alignments, a)
+	}
+	sortAlignments(alignments)
+
+	return e.results(ctx, alignments, queryHashes)
+}
+
+// distance measures how far a variant is from the untouched query, used to
+// prefer the smallest transformation when several score the same
+func (v variant) distance() float64 {
+	return math.Abs(v.SpeedFactor-1)*100 + math.Abs(v.Semitones)
+}
+
+// variant returns the transformation an alignment was found with
+func (a alignment) variant() variant {
+	return variant{SpeedFactor: a.SpeedFactor, Semitones: a.Semitones}
+}
+
+// variant returns the transformation a result was found with
+func (r Result) variant() variant {
+	return variant{SpeedFactor: r.SpeedFactor, Semitones: r.Semitones}
+}
@@ -0,0 +1,69 @@
+package eureka
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSearchVariants(t *testing.T) {
+	tests := []struct {
+		name          string
+		tempo         bool
+		pitch         bool
+		maxChange     float64
+		maxSemitones  float64
+		want          int  // Number of variants
+		wantPaired    bool // Some variants change speed and pitch together
+		wantResampled int  // Variants pairing a shift with the speed resampling gives it
+	}{
+		{name: "no search", want: 1},
+		{name: "tempo only", tempo: true, want: 17},
+		{name: "pitch only", pitch: true, want: 13},
+		{name: "combinations within the limit", tempo: true, pitch: true, maxChange: 0.02, maxSemitones: 1, want: 5 * 5, wantPaired: true},
+		// 17 speeds and 13 shifts make 221 combinations, the 12 non-zero
+		// shifts are tried at the original speed and the resampling speed
+		{name: "axes searched separately over the limit", tempo: true, pitch: true, want: 17 + 2*12, wantPaired: true, wantResampled: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Eureka{}
+			e.Config.Recognition.TempoSearch = tt.tempo
+			e.Config.Recognition.PitchSearch = tt.pitch
+			e.Config.Recognition.TempoMaxChange = tt.maxChange
+			e.Config.Recognition.PitchMaxSemitones = tt.maxSemitones
+
+			variants := e.searchVariants()
+			if len(variants) != tt.want {
+				t.Fatalf("got %d variants, want %d", len(variants), tt.want)
+			}
+			if len(variants) > SEARCH_MAX_VARIANTS {
+				t.Errorf("got %d variants, more than %d", len(variants), SEARCH_MAX_VARIANTS)
+			}
+
+			seen := make(map[variant]bool)
+			paired, resampled := false, 0
+			for _, v := range variants {
+				if seen[v] {
+					t.Errorf("variant %+v tried twice", v)
+				}
+				seen[v] = true
+				if v.SpeedFactor != 1 && v.Semitones != 0 {
+					paired = true
+					if math.Abs(v.SpeedFactor-math.Pow(2, v.Semitones/12)) < 1e-9 {
+						resampled++
+					}
+				}
+			}
+			if !seen[variant{SpeedFactor: 1}] {
+				t.Errorf("unmodified query not tried")
+			}
+			if paired != tt.wantPaired {
+				t.Errorf("speed and pitch changed together = %v, want %v", paired, tt.wantPaired)
+			}
+			if resampled != tt.wantResampled {
+				t.Errorf("got %d resampling variants, want %d", resampled, tt.wantResampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package eureka
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/media-luna/eureka/internal/database"
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	SHARED_MIN_SECONDS          = 2.0 // Shortest overlap between two songs reported
+	SHARED_MIN_HASHES           = 20  // Min number of aligned hashes for an overlap to be reported
+	SHARED_MAX_HASH_OCCURRENCES = 50  // Hashes occurring more often in a song carry no position information and are skipped
+)
+
+// SharedSegment is a stretch of audio found in two songs of the catalogue
+type SharedSegment struct {
+	SongIDA       int     `json:"song_id_a"`
+	SongNameA     string  `json:"song_name_a"`
+	ArtistA       string  `json:"artist_a"`
+	SongIDB       int     `json:"song_id_b"`
+	SongNameB     string  `json:"song_name_b"`
+	ArtistB       string  `json:"artist_b"`
+	StartA        float64 `json:"start_a"`        // Start of the overlap in the first song, in seconds
+	EndA          float64 `json:"end_a"`          // End of the overlap in the first song, in seconds
+	StartB        float64 `json:"start_b"`        // Start of the overlap in the second song, in seconds
+	EndB          float64 `json:"end_b"`          // End of the overlap in the second song, in seconds
+	AlignedHashes int     `json:"aligned_hashes"` // Number of hashes that agree on the overlap
+	Strength      float64 `json:"strength"`       // Share of the first song's hashes within the overlap that aligned
+}
+
+// FindSharedSegments runs the stored fingerprints of every song against the
+// rest of the catalogue and reports the parts of songs that share audio, such
+// as samples, edits, remasters or compilation tracks. Each pair of songs is
+// reported once, with the lower song ID first.
+//
+// Returns:
+//   - The shared segments ordered by song pair and position.
+//   - An error if the songs or their fingerprints could not be loaded.
+func (e *Eureka) FindSharedSegments() ([]SharedSegment, error) {
+	songs, err := e.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing songs: %v", err)
+	}
+
+	sort.Slice(songs, func(i, j int) bool { return songs[i].ID < songs[j].ID })
+
+	var segments []SharedSegment
+	for i, song := range songs {
+		if !song.Fingerprinted {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Scanning song %d/%d: %s", i+1, len(songs), song.Name))
+
+		fingerprints, err := e.database.GetSongFingerprints(song.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading fingerprints of song %d: %v", song.ID, err)
+		}
+
+		// Common hashes are looked up too, since IDF weighting would skip
+		// exactly the audio shared by many songs
+		matches, err := e.database.ReturnMatches(context.Background(), uniqueHashes(fingerprints), database.Filter{})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up fingerprints of song %d: %v", song.ID, err)
+		}
+
+		for _, segment := range sharedSegments(song.ID, fingerprints, matches) {
+			segment.SongNameA, segment.ArtistA = song.Name, song.Artist
+			segments = append(segments, segment)
+		}
+	}
+
+	// Load the matched songs in a single query
+	var otherIDs []int
+	seen := make(map[int]bool)
+	for _, segment := range segments {
+		if !seen[segment.SongIDB] {
+			seen[segment.SongIDB] = true
+			otherIDs = append(otherIDs, segment.SongIDB)
+		}
+	}
+	others, err := e.database.GetSongsByIDs(context.Background(), otherIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading matched songs: %v", err)
+	}
+	for i := range segments {
+		other := others[segments[i].SongIDB]
+		segments[i].SongNameB, segments[i].ArtistB = other.Name, other.Artist
+	}
+
+	logger.Info(fmt.Sprintf("Found %d shared segments", len(segments)))
+
+	return segments, nil
+}
+
+// sharedSegments aligns the fingerprints of a song with the stored
+// fingerprints sharing its hashes and returns the continuous overlaps with
+// every song of a higher ID.
+func sharedSegments(songID int, fingerprints []fingerprint.Fingerprint, matches []fingerprint.Fingerprint) []SharedSegment {
+	offsets := make(map[string][]int)
+	all := make([]int, 0, len(fingerprints))
+	for _, fp := range fingerprints {
+		offsets[fp.Hash] = append(offsets[fp.Hash], fp.Offset)
+		all = append(all, fp.Offset)
+	}
+	sort.Ints(all)
+
+	// Positions in the first song of every aligned hash, by song and lag
+	positions := make(map[int]map[int][]int)
+	for _, m := range matches {
+		list := offsets[m.Hash]
+		if m.SongID <= songID || len(list) > SHARED_MAX_HASH_OCCURRENCES {
+			continue
+		}
+		if positions[m.SongID] == nil {
+			positions[m.SongID] = make(map[int][]int)
+		}
+		for _, offset := range list {
+			positions[m.SongID][m.Offset-offset] = append(positions[m.SongID][m.Offset-offset], offset)
+		}
+	}
+
+	var segments []SharedSegment
+	for other, lags := range positions {
+		var accepted []repeatPair
+		for _, run := range lagRuns(lags, SHARED_MIN_HASHES, int(SHARED_MIN_SECONDS*1000)) {
+			// Weaker lags overlapping a stronger overlap are echoes of it
+			if overlapsRun(run, accepted) {
+				continue
+			}
+			accepted = append(accepted, run)
+
+			// Hashes of the first song inside the overlap
+			total := sort.SearchInts(all, run.End+1) - sort.SearchInts(all, run.Start)
+			segments = append(segments, SharedSegment{
+				SongIDA:       songID,
+				SongIDB:       other,
+				StartA:        float64(run.Start) / 1000,
+				EndA:          float64(run.End) / 1000,
+				StartB:        float64(run.Start+run.Lag) / 1000,
+				EndB:          float64(run.End+run.Lag) / 1000,
+				AlignedHashes: run.Count,
+				Strength:      confidence(run.Count, total),
+			})
+		}
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].SongIDB != segments[j].SongIDB {
+			return segments[i].SongIDB < segments[j].SongIDB
+		}
+		return segments[i].StartA < segments[j].StartA
+	})
+
+	return segments
+}
+
+// overlapsRun reports whether a run overlaps any of the given runs in either song
+func overlapsRun(run repeatPair, runs []repeatPair) bool {
+	for _, r := range runs {
+		if overlapRatio(run.Start, run.End, r.Start, r.End) > 0 ||
+			overlapRatio(run.Start+run.Lag, run.End+run.Lag, r.Start+r.Lag, r.End+r.Lag) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteSharedSegmentsJSON encodes shared segments as a JSON array
+func WriteSharedSegmentsJSON(w io.Writer, segments []SharedSegment) error {
+	if segments == nil {
+		segments = []SharedSegment{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(segments); err != nil {
+		return fmt.Errorf("error encoding shared segments: %v", err)
+	}
+
+	return nil
+}
+
+// WriteSharedSegmentsCSV encodes shared segments as CSV with a header row
+// using the same column names as the JSON report
+func WriteSharedSegmentsCSV(w io.Writer, segments []SharedSegment) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{
+		"song_id_a", "song_name_a", "artist_a", "song_id_b", "song_name_b", "artist_b",
+		"start_a", "end_a", "start_b", "end_b", "aligned_hashes", "strength",
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding shared segments: %v", err)
+	}
+
+	for _, s := range segments {
+		err := writer.Write([]string{
+			strconv.Itoa(s.SongIDA), s.SongNameA, s.ArtistA,
+			strconv.Itoa(s.SongIDB), s.SongNameB, s.ArtistB,
+			strconv.FormatFloat(s.StartA, 'f', 3, 64), strconv.FormatFloat(s.EndA, 'f', 3, 64),
+			strconv.FormatFloat(s.StartB, 'f', 3, 64), strconv.FormatFloat(s.EndB, 'f', 3, 64),
+			strconv.Itoa(s.AlignedHashes), strconv.FormatFloat(s.Strength, 'f', 3, 64),
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding shared segments: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error encoding shared segments: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package eureka
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/media-luna/eureka/internal/fingerprint"
+)
+
+// testHashes returns count fingerprints with hashes prefix0, prefix1, ...
+// every 100ms from start, in milliseconds
+func testHashes(prefix string, count int, start int) []fingerprint.Fingerprint {
+	fingerprints := make([]fingerprint.Fingerprint, count)
+	for i := range fingerprints {
+		fingerprints[i] = fingerprint.Fingerprint{Hash: fmt.Sprintf("%s%d", prefix, i), Offset: start + 100*i}
+	}
+	return fingerprints
+}
+
+func TestFindSharedSegments(t *testing.T) {
+	// repeated holds the same hash every 10ms, more often than it can tell positions apart
+	repeated := make([]fingerprint.Fingerprint, SHARED_MAX_HASH_OCCURRENCES+1)
+	for i := range repeated {
+		repeated[i] = fingerprint.Fingerprint{Hash: "beat", Offset: 10 * i}
+	}
+	songA := append(testHashes("a", 50, 0), repeated...)
+
+	tests := []struct {
+		name  string
+		songs map[int][]fingerprint.Fingerprint
+		want  []SharedSegment // Song names are checked separately, Strength is not compared
+	}{
+		{
+			name: "sampled stretch",
+			songs: map[int][]fingerprint.Fingerprint{
+				1: songA,
+				2: append(testHashes("b", 20, 0), testHashes("a", 30, 10000)...),
+				3: testHashes("c", 50, 0),
+			},
+			want: []SharedSegment{{SongIDA: 1, SongIDB: 2, StartA: 0, EndA: 2.9, StartB: 10, EndB: 12.9, AlignedHashes: 30}},
+		},
+		{
+			name: "lower song ID first",
+			songs: map[int][]fingerprint.Fingerprint{
+				1: testHashes("a", 30, 10000),
+				2: songA,
+			},
+			want: []SharedSegment{{SongIDA: 1, SongIDB: 2, StartA: 10, EndA: 12.9, StartB: 0, EndB: 2.9, AlignedHashes: 30}},
+		},
+		{
+			name: "too few shared hashes",
+			songs: map[int][]fingerprint.Fingerprint{
+				1: songA,
+				2: testHashes("a", SHARED_MIN_HASHES-1, 5000),
+			},
+		},
+		{
+			name: "frequent hashes skipped",
+			songs: map[int][]fingerprint.Fingerprint{
+				1: songA,
+				2: repeated,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB()
+			for id, fingerprints := range tt.songs {
+				db.addSong(id, fmt.Sprintf("song %d", id), fingerprints)
+			}
+
+			segments, err := newTestEureka(db).FindSharedSegments()
+			if err != nil {
+				t.Fatalf("FindSharedSegments() error = %v", err)
+			}
+			if len(segments) != len(tt.want) {
+				t.Fatalf("FindSharedSegments() = %+v, want %d segments", segments, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := segments[i]
+				if got.SongIDA != want.SongIDA || got.SongIDB != want.SongIDB || got.AlignedHashes != want.AlignedHashes {
+					t.Errorf("segment %d = %+v, want %+v", i, got, want)
+				}
+				for _, pair := range [][2]float64{{got.StartA, want.StartA}, {got.EndA, want.EndA}, {got.StartB, want.StartB}, {got.EndB, want.EndB}} {
+					if math.Abs(pair[0]-pair[1]) > 1e-9 {
+						t.Errorf("segment %d = %+v, want %+v", i, got, want)
+						break
+					}
+				}
+				if got.SongNameA != fmt.Sprintf("song %d", want.SongIDA) || got.SongNameB != fmt.Sprintf("song %d", want.SongIDB) {
+					t.Errorf("segment %d names %q and %q", i, got.SongNameA, got.SongNameB)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package eureka
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	DEFAULT_STATUS_MIN_ALIGNED_HASHES = 20   // Aligned hashes a match needs when recognition.status_min_aligned_hashes is not set
+	DEFAULT_STATUS_MIN_MARGIN         = 1.5  // Score ratio between the top two candidates when recognition.status_min_margin is not set
+	DEFAULT_STATUS_MIN_SECONDS        = 3.0  // Audio always asked for when recognition.status_min_seconds is not set
+	DEFAULT_STATUS_MAX_SECONDS        = 20.0 // Audio after which a query is given up when recognition.status_max_seconds is not set
+	STATUS_MIN_CANDIDATES             = 2    // Candidates fetched for Decide whatever recognition.top_results is
+)
+
+// Status tells a client whether to keep sending audio
+type Status string
+
+const (
+	STATUS_MATCHED          Status = "matched"          // The top candidate is strong and clearly ahead of the others
+	STATUS_NO_MATCH         Status = "no_match"         // More audio is unlikely to produce a match
+	STATUS_NEEDS_MORE_AUDIO Status = "needs_more_audio" // More audio will likely decide between match and no match
+)
+
+// Decision is the status of a query with the audio it was given
+type Decision struct {
+	Status       Status  `json:"status"`
+	QuerySeconds float64 `json:"query_seconds"` // Audio the decision is based on
+	MoreSeconds  float64 `json:"more_seconds"`  // Extra audio likely to decide the query, 0 unless more audio is needed
+}
+
+// Decide tells whether the results of a query are a match, cannot become one,
+// or need more audio. A match needs recognition.status_min_aligned_hashes
+// aligned hashes and a score recognition.status_min_margin times the score of
+// the runner-up. Otherwise the extra audio needed is estimated by assuming
+// that the top candidate keeps aligning hashes at its current rate, while the
+// runner-up, whose hashes agree by chance, stays where it is. A query is
+// given up when the estimate would take it past
+// recognition.status_max_seconds, and always given
+// recognition.status_min_seconds of audio.
+//
+// Voting results are measured on a single sub-window, so their rate is taken
+// over the sub-window length rather than the whole query. Runner-ups holding
+// the same audio as the top candidate, such as a song stored twice, never
+// fall behind it and are not compared against, see runnerUpScore. The results
+// must include the runner-up, see withStatusCandidates.
+//
+// Parameters:
+//   - results: The results of the query, best first.
+//   - querySeconds: The length of the query audio.
+//
+// Returns:
+//   - The decision.
+func (e *Eureka) Decide(results []Result, querySeconds float64) Decision {
+	cfg := e.Config.Recognition
+	thresholds := e.thresholds()
+	minAligned := thresholds.statusMinAlignedHashes
+	if minAligned <= 0 {
+		minAligned = DEFAULT_STATUS_MIN_ALIGNED_HASHES
+	}
+	minMargin := cfg.StatusMinMargin
+	if minMargin <= 0 {
+		minMargin = DEFAULT_STATUS_MIN_MARGIN
+	}
+	minSeconds := cfg.StatusMinSeconds
+	if minSeconds <= 0 {
+		minSeconds = DEFAULT_STATUS_MIN_SECONDS
+	}
+	maxSeconds := thresholds.statusMaxSeconds
+	if maxSeconds <= 0 {
+		maxSeconds = DEFAULT_STATUS_MAX_SECONDS
+	}
+
+	decision := Decision{QuerySeconds: querySeconds}
+
+	var top, runnerUp float64
+	aligned := 0
+	rateSeconds := querySeconds
+	if len(results) > 0 {
+		top, aligned = results[0].Score, results[0].AlignedHashes
+		runnerUp = runnerUpScore(results)
+		if results[0].Windows > 0 {
+			rateSeconds = math.Min(e.voteWindowSeconds(), querySeconds)
+		}
+	}
+
+	if aligned >= minAligned && top >= minMargin*runnerUp {
+		decision.Status = STATUS_MATCHED
+		return decision
+	}
+
+	more := math.Inf(1)
+	if aligned > 0 && rateSeconds > 0 {
+		// Time for the aligned hashes to reach the minimum at the current rate
+		rate := float64(aligned) / rateSeconds
+		more = math.Max(float64(minAligned-aligned)/rate, 0)
+
+		// Time for the top score to pull minMargin ahead of the runner-up
+		if top > 0 && top < minMargin*runnerUp {
+			more = math.Max(more, querySeconds*(minMargin*runnerUp/top-1))
+		}
+	}
+	more = math.Max(more, minSeconds-querySeconds)
+
+	if math.IsInf(more, 1) {
+		if querySeconds < minSeconds {
+			decision.Status = STATUS_NEEDS_MORE_AUDIO
+			decision.MoreSeconds = minSeconds - querySeconds
+			return decision
+		}
+		decision.Status = STATUS_NO_MATCH
+		return decision
+	}
+
+	if querySeconds+more > maxSeconds {
+		decision.Status = STATUS_NO_MATCH
+		return decision
+	}
+
+	decision.Status = STATUS_NEEDS_MORE_AUDIO
+	decision.MoreSeconds = math.Ceil(more*10) / 10
+
+	return decision
+}
+
+// runnerUpScore returns the score of the best candidate after the top one
+// that holds different audio. Candidates stored from the same file or under
+// the same name and artist match the query just as well and are skipped.
+func runnerUpScore(results []Result) float64 {
+	top := results[0]
+	for _, result := range results[1:] {
+		if sameRecording(top, result) {
+			continue
+		}
+		return result.Score
+	}
+	return 0
+}
+
+// withStatusCandidates returns a copy of e keeping at least
+// STATUS_MIN_CANDIDATES results, so that Decide sees a runner-up even when
+// recognition.top_results keeps a single one. Callers return the results cut
+// back to recognition.top_results. Like WithFilter, the copy shares the
+// databases and the cache of e.
+func (e *Eureka) withStatusCandidates() *Eureka {
+	topResults := max(e.topResults(), STATUS_MIN_CANDIDATES)
+
+	widened := *e
+	widened.Config.Recognition.TopResults = topResults
+
+	widened.catalogues = make([]catalogue, len(e.catalogues))
+	for i, c := range e.catalogues {
+		child := *c.eureka
+		child.Config.Recognition.TopResults = topResults
+		widened.catalogues[i] = catalogue{name: c.name, eureka: &child}
+	}
+
+	return &widened
+}
+
+// sameRecording reports whether two results are copies of one recording
+func sameRecording(a, b Result) bool {
+	if a.FileSHA1 != "" && a.FileSHA1 == b.FileSHA1 {
+		return true
+	}
+	return a.SongName != "" && strings.EqualFold(a.SongName, b.SongName) && strings.EqualFold(a.Artist, b.Artist)
+}
@@ -0,0 +1,133 @@
+package eureka
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name         string
+		profile      string
+		shortAligned int // short_reference.status_min_aligned_hashes
+		results      []Result
+		seconds      float64
+		want         Status
+		wantMore     float64
+	}{
+		{name: "no candidates yet", seconds: 1, want: STATUS_NEEDS_MORE_AUDIO, wantMore: 2},
+		{name: "no candidates", seconds: 5, want: STATUS_NO_MATCH},
+		{
+			name:    "clear match",
+			results: []Result{{SongName: "a", AlignedHashes: 40, Score: 40}, {SongName: "b", AlignedHashes: 5, Score: 5}},
+			seconds: 5,
+			want:    STATUS_MATCHED,
+		},
+		{
+			name:     "runner-up too close",
+			results:  []Result{{SongName: "a", AlignedHashes: 40, Score: 40}, {SongName: "b", AlignedHashes: 30, Score: 30}},
+			seconds:  5,
+			want:     STATUS_NEEDS_MORE_AUDIO,
+			wantMore: 0.7,
+		},
+		{
+			name:     "tied different songs",
+			results:  []Result{{SongName: "a", AlignedHashes: 40, Score: 40}, {SongName: "b", AlignedHashes: 40, Score: 40}},
+			seconds:  5,
+			want:     STATUS_NEEDS_MORE_AUDIO,
+			wantMore: 2.5,
+		},
+		{
+			name:    "copies of the top candidate ignored",
+			results: []Result{{SongName: "a", Artist: "x", AlignedHashes: 40, Score: 40}, {SongName: "A", Artist: "X", AlignedHashes: 39, Score: 39}},
+			seconds: 5,
+			want:    STATUS_MATCHED,
+		},
+		{
+			name:     "weak candidate growing",
+			results:  []Result{{SongName: "a", AlignedHashes: 10, Score: 10}},
+			seconds:  5,
+			want:     STATUS_NEEDS_MORE_AUDIO,
+			wantMore: 5,
+		},
+		{
+			name:    "weak candidate too slow",
+			results: []Result{{SongName: "a", AlignedHashes: 2, Score: 2}},
+			seconds: 5,
+			want:    STATUS_NO_MATCH,
+		},
+		{
+			name:         "short profile thresholds",
+			profile:      PROFILE_SHORT,
+			shortAligned: 5,
+			results:      []Result{{SongName: "a", AlignedHashes: 6, Score: 6}},
+			seconds:      5,
+			want:         STATUS_MATCHED,
+		},
+		{
+			name:         "default profile ignores short thresholds",
+			profile:      PROFILE_DEFAULT,
+			shortAligned: 5,
+			results:      []Result{{SongName: "a", AlignedHashes: 6, Score: 6}},
+			seconds:      5,
+			want:         STATUS_NEEDS_MORE_AUDIO,
+			wantMore:     11.7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Eureka{}
+			e.Config.Config.Profile = tt.profile
+			e.Config.ShortReference.StatusMinAlignedHashes = tt.shortAligned
+
+			decision := e.Decide(tt.results, tt.seconds)
+			if decision.Status != tt.want || decision.MoreSeconds != tt.wantMore {
+				t.Errorf("got %s with %vs more, want %s with %vs more", decision.Status, decision.MoreSeconds, tt.want, tt.wantMore)
+			}
+			if decision.QuerySeconds != tt.seconds {
+				t.Errorf("got query seconds %v, want %v", decision.QuerySeconds, tt.seconds)
+			}
+		})
+	}
+}
+
+func TestStreamDecideSeesRunnerUp(t *testing.T) {
+	song := testTones(1, 30)
+	fingerprints := testSongFingerprints(t, song)
+
+	tests := []struct {
+		name   string
+		copies int // Songs stored with the same audio under different names
+		want   Status
+	}{
+		{name: "single song", copies: 1, want: STATUS_MATCHED},
+		{name: "song stored under two names", copies: 2, want: STATUS_NEEDS_MORE_AUDIO},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB()
+			for id := 1; id <= tt.copies; id++ {
+				db.addSong(id, string(rune('a'+id-1)), fingerprints)
+			}
+			e := newTestEureka(db)
+			e.Config.Recognition.TopResults = 1
+
+			s, err := e.NewStreamRecognizer(testSampleRate, 1)
+			if err != nil {
+				t.Fatalf("NewStreamRecognizer() error = %v", err)
+			}
+			results, err := s.Recognize(bytes.NewReader(testPCM(song[:5*testSampleRate])), nil)
+			if err != nil {
+				t.Fatalf("Recognize() error = %v", err)
+			}
+			if len(results) != 1 {
+				t.Errorf("got %d results, want top_results of them", len(results))
+			}
+			if decision := s.Decide(); decision.Status != tt.want {
+				t.Errorf("got %s, want %s", decision.Status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	DEFAULT_STREAM_SEGMENT_SECONDS = 2.0  // Seconds of audio fingerprinted at once when recognition.stream_segment_seconds is not set
+	DEFAULT_STREAM_WINDOW_SECONDS  = 30.0 // Seconds of recent audio candidates are ranked against when recognition.stream_window_seconds is not set
+	STREAM_CONTEXT_FRAMES          = 4    // Frames of audio fingerprinted again with the next segment, see StreamRecognizer.process
+)
+
+// StreamRecognizer identifies audio read incrementally from a stream of raw
+// 16-bit little-endian PCM. Audio is fingerprinted one segment at a time and
+// only hashes that were not looked up before are sent to the database.
+// Candidates are ranked against the last recognition.stream_window_seconds of
+// audio only, so the cost of every update stays bounded however long the
+// stream runs.
+type StreamRecognizer struct {
+	eureka       *Eureka  // Keeps a runner-up for Decide, see withStatusCandidates
+	topResults   int      // Number of candidates returned to the caller
+	candidates   []Result // Candidates of the audio read so far, before the top_results cut
+	sampleRate   int
+	channels     int
+	fingerprints []fingerprint.Fingerprint // Fingerprints within the window
+	catalogues   []streamCatalogue         // Lookup state of every searched database
+	tail         []float64                 // Last samples read, fingerprinted again with the next segment
+	carried      []fingerprint.Peak        // Last peaks, paired with the peaks of the next segment
+	frames       int                       // Number of spectrogram frames read
+	picked       int                       // Number of spectrogram frames whose peaks were picked
+}
+
+// streamCatalogue holds what a stream looked up in one database. A catalogue
+// that misses an update keeps its previous state and looks the missed hashes
+// up again with the next segment.
+type streamCatalogue struct {
+	matches []fingerprint.Fingerprint
+	weights map[string]float64
+	queried map[string]bool
+	results []Result
+}
+
+// NewStreamRecognizer creates a StreamRecognizer for PCM audio with the given
+// sample rate and number of interleaved channels.
+func (e *Eureka) NewStreamRecognizer(sampleRate int, channels int) (*StreamRecognizer, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("sample rate must be positive")
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("channel count must be positive")
+	}
+
+	catalogues := make([]streamCatalogue, max(len(e.catalogues), 1))
+	for i := range catalogues {
+		catalogues[i].queried = make(map[string]bool)
+	}
+
+	return &StreamRecognizer{
+		eureka:     e.withStatusCandidates(),
+		topResults: e.topResults(),
+		sampleRate: sampleRate,
+		channels:   channels,
+		catalogues: catalogues,
+	}, nil
+}
+
+// Recognize reads PCM audio from r until EOF or until Decide tells that the
+// candidates are a match or cannot become one, so clients only stream as long
+// as necessary. A stream that ends without a match is kept as an unknown query
+// when unknown.record is set.
+//
+// Parameters:
+//   - r: The reader providing raw interleaved 16-bit little-endian PCM audio.
+//   - onUpdate: Called with the current candidates after every segment, may be nil.
+//
+// Returns:
+//   - The candidates known when the stream stopped.
+//   - An error if reading, fingerprinting or the database lookup failed.
+func (s *StreamRecognizer) Recognize(r io.Reader, onUpdate func([]Result)) ([]Result, error) {
+	buf := make([]byte, s.segmentSamples()*s.channels*2)
+	var results []Result
+
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return results, fmt.Errorf("error reading stream: %v", readErr)
+		}
+
+		// Drop a trailing partial frame so the PCM decodes cleanly
+		n -= n % (s.channels * 2)
+		if n > 0 {
+			samples, err := fingerprint.DecodePCM16(buf[:n], s.channels)
+			if err != nil {
+				return results, fmt.Errorf("error decoding PCM: %v", err)
+			}
+
+			s.candidates, err = s.process(samples)
+			if err != nil {
+				return results, err
+			}
+			results = limitResults(s.candidates, s.topResults)
+
+			if onUpdate != nil {
+				onUpdate(results)
+			}
+
+			switch s.Decide().Status {
+			case STATUS_MATCHED:
+				logger.Info(fmt.Sprintf("Stream recognized after %.1fs of audio", s.elapsedSeconds()))
+				return results, nil
+			case STATUS_NO_MATCH:
+				logger.Info(fmt.Sprintf("Stream not recognized after %.1fs of audio", s.elapsedSeconds()))
+				readErr = io.EOF
+			}
+		}
+
+		if readErr != nil {
+			if s.eureka.unmatched(results) {
+				if err := s.eureka.recordUnknown(s.fingerprints, "stream", 0); err != nil {
+					return results, err
+				}
+			}
+			return results, nil
+		}
+	}
+}
+
+// process fingerprints a segment of samples, looks up its new hashes and
+// ranks the candidates against the fingerprints within the window.
+//
+// The first and last frame of a spectrogram lack the neighbouring samples of
+// the low-pass filter, and picking the peaks of a frame compares it with the
+// frames on both sides, so the peaks of the last two frames of a segment
+// are not picked until the next segment arrives. The next segment is
+// fingerprinted together with the last STREAM_CONTEXT_FRAMES frames of audio,
+// which gives every frame the same peaks it has in the stream as a whole.
+func (s *StreamRecognizer) process(samples []float64) ([]Result, error) {
+	profile := s.eureka.fingerprintProfile()
+	buf := append(s.tail[:len(s.tail):len(s.tail)], samples...)
+	base := s.frames - len(s.tail)/fingerprint.WINDOW_SIZE
+
+	spectrogram, err := fingerprint.SamplesToStreamSpectrogram(buf, s.sampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("error creating spectrogram: %v", err)
+	}
+	s.frames += len(samples) / fingerprint.WINDOW_SIZE
+	s.tail = append([]float64(nil), buf[max(len(buf)-STREAM_CONTEXT_FRAMES*fingerprint.WINDOW_SIZE, 0):]...)
+
+	end := len(spectrogram) - STREAM_CONTEXT_FRAMES/2
+	var peaks []fingerprint.Peak
+	for _, peak := range fingerprint.PickPeaks(spectrogram, s.sampleRate, profile) {
+		if frame := int(peak.Time); base+frame >= s.picked && frame < end {
+			peaks = append(peaks, peak)
+		}
+	}
+	s.picked = max(s.picked, base+end)
+
+	fingerprints, carried := fingerprint.ExtendFingerprints(s.carried, offsetPeaks(peaks, base, s.sampleRate), profile)
+	s.carried = carried
+	s.fingerprints = append(s.fingerprints, fingerprints...)
+	s.slide()
+	all := s.fingerprints
+
+	// Catalogues may answer after the timeout, so they only read the state
+	// captured here and return their new state instead of changing it
+	hashes := uniqueHashes(all)
+	pending := make([][]string, len(s.catalogues))
+	for i, c := range s.catalogues {
+		for _, hash := range hashes {
+			if !c.queried[hash] {
+				pending[i] = append(pending[i], hash)
+			}
+		}
+	}
+	states := append([]streamCatalogue(nil), s.catalogues...)
+
+	replies, _, err := queryCatalogues(s.eureka, func(ctx context.Context, i int, c *Eureka) (streamCatalogue, error) {
+		state := states[i]
+		if len(pending[i]) > 0 {
+			matches, weights, err := c.lookup(ctx, pending[i])
+			if err != nil {
+				return state, err
+			}
+			state.matches = append(state.matches[:len(state.matches):len(state.matches)], matches...)
+			if weights != nil {
+				merged := make(map[string]float64, len(state.weights)+len(weights))
+				for hash, weight := range state.weights {
+					merged[hash] = weight
+				}
+				for hash, weight := range weights {
+					merged[hash] = weight
+				}
+				state.weights = merged
+			}
+		}
+
+		results, err := c.rank(ctx, all, state.matches, state.weights)
+		if err != nil {
+			return state, err
+		}
+		state.results = results
+		return state, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reply := range replies {
+		state := reply.value
+		state.queried = s.catalogues[reply.index].queried
+		for _, hash := range pending[reply.index] {
+			state.queried[hash] = true
+		}
+		s.catalogues[reply.index] = state
+	}
+
+	lists := make([][]Result, len(s.catalogues))
+	names := make([]string, len(s.catalogues))
+	for i, c := range s.catalogues {
+		lists[i] = c.results
+		if len(s.eureka.catalogues) > 0 {
+			names[i] = s.eureka.catalogues[i].name
+		}
+	}
+
+	return s.eureka.mergeResults(lists, names), nil
+}
+
+// slide drops the fingerprints that fell out of the window, along with the
+// stored fingerprints and weights looked up for hashes no longer in it. A
+// dropped hash is looked up again if it comes back.
+func (s *StreamRecognizer) slide() {
+	seconds := s.eureka.Config.Recognition.StreamWindowSeconds
+	if seconds <= 0 {
+		seconds = DEFAULT_STREAM_WINDOW_SECONDS
+	}
+	cutoff := int((s.elapsedSeconds() - seconds) * 1000)
+	if cutoff <= 0 {
+		return
+	}
+
+	var kept []fingerprint.Fingerprint
+	hashes := make(map[string]bool)
+	for _, fp := range s.fingerprints {
+		if fp.Offset >= cutoff {
+			kept = append(kept, fp)
+			hashes[fp.Hash] = true
+		}
+	}
+	if len(kept) == len(s.fingerprints) {
+		return
+	}
+	s.fingerprints = kept
+
+	// Catalogues that timed out may still read the previous state, so the
+	// pruned fingerprints, matches and weights are copies
+	for i := range s.catalogues {
+		c := &s.catalogues[i]
+		var matches []fingerprint.Fingerprint
+		for _, m := range c.matches {
+			if hashes[m.Hash] {
+				matches = append(matches, m)
+			}
+		}
+		c.matches = matches
+
+		if c.weights != nil {
+			weights := make(map[string]float64, len(hashes))
+			for hash, weight := range c.weights {
+				if hashes[hash] {
+					weights[hash] = weight
+				}
+			}
+			c.weights = weights
+		}
+
+		for hash := range c.queried {
+			if !hashes[hash] {
+				delete(c.queried, hash)
+			}
+		}
+	}
+}
+
+// Decide tells whether the candidates of the audio read so far are a match,
+// cannot become one or need more audio. It also compares the top candidate
+// with a runner-up that recognition.top_results may leave out of the results.
+func (s *StreamRecognizer) Decide() Decision {
+	return s.eureka.Decide(s.candidates, s.elapsedSeconds())
+}
+
+// segmentSamples returns the number of mono samples fingerprinted at once,
+// rounded down to whole spectrogram frames so that segments stay aligned to
+// the frame grid of the stream.
+func (s *StreamRecognizer) segmentSamples() int {
+	seconds := s.eureka.Config.Recognition.StreamSegmentSeconds
+	if seconds <= 0 {
+		seconds = DEFAULT_STREAM_SEGMENT_SECONDS
+	}
+
+	frames := int(seconds * float64(s.sampleRate) / float64(fingerprint.WINDOW_SIZE))
+	if frames < 1 {
+		frames = 1
+	}
+	return frames * fingerprint.WINDOW_SIZE
+}
+
+// elapsedSeconds returns the amount of audio read so far
+func (s *StreamRecognizer) elapsedSeconds() float64 {
+	return float64(s.frames*fingerprint.WINDOW_SIZE) / float64(s.sampleRate)
+}
@@ -0,0 +1,226 @@
+package eureka
+
+import (
+	"fmt"
+	"math"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	SYNC_CHUNK_SECONDS       = 30.0 // Length of the chunks long recordings are fingerprinted in
+	SYNC_CORRELATION_SECONDS = 2.0  // Length of audio cross-correlated to refine an offset
+	SYNC_DRIFT_TOLERANCE_MS  = 200  // Max distance from the best offset of hashes used to estimate drift
+	SYNC_MIN_DRIFT_PAIRS     = 10   // Min number of aligned hashes needed to estimate drift
+)
+
+// SyncOffset represents the position of a recording relative to a reference
+type SyncOffset struct {
+	Path          string  `json:"path"`
+	OffsetSamples int     `json:"offset_samples"` // Position of the recording start within the reference, in samples
+	OffsetSeconds float64 `json:"offset_seconds"` // Offset expressed in seconds
+	AlignedHashes int     `json:"aligned_hashes"` // Number of hashes that agree on the coarse offset
+	Correlation   float64 `json:"correlation"`    // Normalized cross-correlation at the refined offset, 0 if not refined
+	DriftPPM      float64 `json:"drift_ppm"`      // Clock drift relative to the reference, in parts per million
+}
+
+// Synchronize computes the offset of each recording relative to the first one.
+// A coarse offset comes from the fingerprint offset histogram, a drift estimate
+// from the slope of the aligned hashes over time, and the offset is then
+// refined to the sample with a normalized cross-correlation around the coarse
+// value.
+//
+// Parameters:
+//   - paths: The recordings to align, the first one is the reference.
+//   - profile: The peak picking and pairing settings of the fingerprints.
+//
+// Returns:
+//   - One offset per recording, the reference first with a zero offset.
+//   - An error if a recording could not be processed or the sample rates differ.
+func Synchronize(paths []string, profile fingerprint.Profile) ([]SyncOffset, error) {
+	if len(paths) < 2 {
+		return nil, fmt.Errorf("at least two recordings are needed")
+	}
+
+	refSamples, refRate, err := loadSamples(paths[0])
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s: %v", paths[0], err)
+	}
+	refFingerprints, err := fingerprintChunked(refSamples, refRate, SYNC_CHUNK_SECONDS, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error fingerprinting %s: %v", paths[0], err)
+	}
+
+	offsets := []SyncOffset{{Path: paths[0], AlignedHashes: len(refFingerprints), Correlation: 1}}
+	for _, path := range paths[1:] {
+		samples, sampleRate, err := loadSamples(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading %s: %v", path, err)
+		}
+		if sampleRate != refRate {
+			return nil, fmt.Errorf("sample rate of %s is %d, expected %d like the reference", path, sampleRate, refRate)
+		}
+
+		fingerprints, err := fingerprintChunked(samples, sampleRate, SYNC_CHUNK_SECONDS, profile)
+		if err != nil {
+			return nil, fmt.Errorf("error fingerprinting %s: %v", path, err)
+		}
+
+		offset := synchronizeRecording(refSamples, refFingerprints, samples, fingerprints, sampleRate)
+		offset.Path = path
+		offsets = append(offsets, offset)
+		logger.Info(fmt.Sprintf("Aligned %s at %.6fs", path, offset.OffsetSeconds))
+	}
+
+	return offsets, nil
+}
+
+// synchronizeRecording computes the offset of one recording within the reference
+func synchronizeRecording(refSamples []float64, refFingerprints []fingerprint.Fingerprint,
+	samples []float64, fingerprints []fingerprint.Fingerprint, sampleRate int) SyncOffset {
+	var offset SyncOffset
+
+	alignments := alignMatches(fingerprints, refFingerprints, nil)
+	if len(alignments) == 0 {
+		return offset
+	}
+	coarse := alignments[0]
+	offset.AlignedHashes = coarse.Count
+
+	// The aligned hashes give a first estimate of the offset and drift, only
+	// precise to a spectrogram frame
+	interceptMS, slope := driftFit(refFingerprints, fingerprints, coarse.Offset)
+	lagAtStart := interceptMS * float64(sampleRate) / 1000
+	offset.OffsetSamples = int(math.Round(lagAtStart))
+	offset.DriftPPM = slope * 1e6
+
+	// Refine the lag by cross-correlation at both ends of the overlap, the
+	// drift follows from how much the lag changes between them
+	radius := fingerprint.WINDOW_SIZE
+	length := int(SYNC_CORRELATION_SECONDS * float64(sampleRate))
+	first := int(math.Max(0, float64(2*radius)-lagAtStart))
+	last := int(math.Min(float64(len(samples)), float64(len(refSamples))-lagAtStart)) - length - 2*radius
+
+	lag := func(position int) int { return int(math.Round(lagAtStart + slope*float64(position))) }
+	firstLag, correlation, ok := crossCorrelate(refSamples, samples, first, lag(first), radius, length)
+	if !ok {
+		offset.OffsetSeconds = float64(offset.OffsetSamples) / float64(sampleRate)
+		return offset
+	}
+	offset.Correlation = correlation
+
+	if last-first >= length {
+		if lastLag, _, ok := crossCorrelate(refSamples, samples, last, lag(last), radius, length); ok {
+			slope = float64(lastLag-firstLag) / float64(last-first)
+			offset.DriftPPM = slope * 1e6
+		}
+	}
+
+	offset.OffsetSamples = firstLag - int(math.Round(slope*float64(first)))
+	offset.OffsetSeconds = float64(offset.OffsetSamples) / float64(sampleRate)
+
+	return offset
+}
+
+// driftFit fits a line to the offset difference of the hashes aligned around
+// the best offset, as a function of their position in the recording. The
+// intercept is the offset at the start of the recording and the slope is the
+// drift of its clock relative to the reference.
+//
+// Returns:
+//   - The offset at the start of the recording, in milliseconds.
+//   - The drift, in milliseconds per millisecond.
+func driftFit(refFingerprints []fingerprint.Fingerprint, fingerprints []fingerprint.Fingerprint, best int) (float64, float64) {
+	offsets := make(map[string][]int)
+	for _, fp := range fingerprints {
+		offsets[fp.Hash] = append(offsets[fp.Hash], fp.Offset)
+	}
+
+	var n, sumX, sumY, sumXX, sumXY float64
+	for _, ref := range refFingerprints {
+		for _, x := range offsets[ref.Hash] {
+			y := ref.Offset - x
+			if y < best-SYNC_DRIFT_TOLERANCE_MS || y > best+SYNC_DRIFT_TOLERANCE_MS {
+				continue
+			}
+			n++
+			sumX += float64(x)
+			sumY += float64(y)
+			sumXX += float64(x) * float64(x)
+			sumXY += float64(x) * float64(y)
+		}
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if n < SYNC_MIN_DRIFT_PAIRS || denominator == 0 {
+		return float64(best), 0
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+	return intercept, slope
+}
+
+// crossCorrelate searches the lag within radius of predicted that maximizes
+// the normalized cross-correlation between samples, starting at position, and
+// the reference shifted by the lag.
+//
+// Parameters:
+//   - ref: The reference samples.
+//   - samples: The samples of the recording.
+//   - position: The index in samples where the correlated segment starts.
+//   - predicted: The expected lag, ref[position+lag] matching samples[position].
+//   - radius: How many samples around predicted are searched.
+//   - length: The number of samples correlated.
+//
+// Returns:
+//   - The best lag.
+//   - The normalized correlation at that lag.
+//   - Whether the searched lags stay within both recordings and enough
+//     overlapping audio was available to correlate.
+func crossCorrelate(ref []float64, samples []float64, position int, predicted int, radius int, length int) (int, float64, bool) {
+	if position < 0 || position+predicted-radius < 0 {
+		return 0, 0, false
+	}
+	if remaining := len(samples) - position; remaining < length {
+		length = remaining
+	}
+	if remaining := len(ref) - (position + predicted + radius); remaining < length {
+		length = remaining
+	}
+	if length < fingerprint.WINDOW_SIZE {
+		return 0, 0, false
+	}
+
+	segment := samples[position : position+length]
+	segmentEnergy := 0.0
+	for _, v := range segment {
+		segmentEnergy += v * v
+	}
+	if segmentEnergy == 0 {
+		return 0, 0, false
+	}
+
+	bestLag, bestCorrelation := predicted, math.Inf(-1)
+	for lag := predicted - radius; lag <= predicted+radius; lag++ {
+		window := ref[position+lag : position+lag+length]
+		sum, energy := 0.0, 0.0
+		for i, v := range segment {
+			sum += v * window[i]
+			energy += window[i] * window[i]
+		}
+		if energy == 0 {
+			continue
+		}
+		correlation := sum / math.Sqrt(segmentEnergy*energy)
+		if correlation > bestCorrelation {
+			bestLag, bestCorrelation = lag, correlation
+		}
+	}
+
+	if math.IsInf(bestCorrelation, -1) {
+		return 0, 0, false
+	}
+	return bestLag, bestCorrelation, true
+}
@@ -0,0 +1,57 @@
+package eureka
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+)
+
+func TestCrossCorrelate(t *testing.T) {
+	const shift = 300 // Position of the recording start within the reference
+
+	random := rand.New(rand.NewSource(1))
+	ref := make([]float64, 20000)
+	for i := range ref {
+		ref[i] = random.Float64()*2 - 1
+	}
+	samples := ref[shift : shift+10000]
+	silence := make([]float64, len(samples))
+
+	tests := []struct {
+		name      string
+		samples   []float64
+		position  int
+		predicted int
+		radius    int
+		length    int
+		wantLag   int
+		wantOK    bool
+	}{
+		{name: "finds the lag", samples: samples, position: 1000, predicted: shift - 10, radius: 20, length: 4096, wantLag: shift, wantOK: true},
+		{name: "exact prediction", samples: samples, position: 1000, predicted: shift, radius: 0, length: 4096, wantLag: shift, wantOK: true},
+		{name: "length capped by the recording", samples: samples, position: len(samples) - 2048, predicted: shift, radius: 5, length: 4096, wantLag: shift, wantOK: true},
+		{name: "lags before the reference", samples: samples, position: 0, predicted: 5, radius: 10, length: 4096},
+		{name: "too little audio", samples: samples, position: len(samples) - fingerprint.WINDOW_SIZE + 1, predicted: shift, radius: 5, length: 4096},
+		{name: "silent recording", samples: silence, position: 1000, predicted: shift, radius: 5, length: 4096},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lag, correlation, ok := crossCorrelate(ref, tt.samples, tt.position, tt.predicted, tt.radius, tt.length)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %t, want %t", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if lag != tt.wantLag {
+				t.Errorf("got lag %d, want %d", lag, tt.wantLag)
+			}
+			if math.Abs(correlation-1) > 1e-9 {
+				t.Errorf("got correlation %v, want 1", correlation)
+			}
+		})
+	}
+}
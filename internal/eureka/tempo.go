@@ -0,0 +1,28 @@
+package eureka
+
+import (
+	"math"
+)
+
+const (
+	DEFAULT_TEMPO_MAX_CHANGE = 0.08 // Largest speed change searched when recognition.tempo_max_change is not set
+	DEFAULT_TEMPO_STEP       = 0.01 // Step between searched speeds when recognition.tempo_step is not set
+)
+
+// tempoFactors returns the speed factors to search, symmetric around 1
+func tempoFactors(maxChange float64, step float64) []float64 {
+	if maxChange <= 0 {
+		maxChange = DEFAULT_TEMPO_MAX_CHANGE
+	}
+	if step <= 0 {
+		step = DEFAULT_TEMPO_STEP
+	}
+
+	steps := int(math.Round(maxChange / step))
+	factors := make([]float64, 0, 2*steps+1)
+	for i := -steps; i <= steps; i++ {
+		factors = append(factors, 1+float64(i)*step)
+	}
+
+	return factors
+}
@@ -0,0 +1,246 @@
+package eureka
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/media-luna/eureka/internal/database"
+	fingerprint "github.com/media-luna/eureka/internal/fingerprint"
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	DEFAULT_UNKNOWN_MIN_ALIGNED_HASHES = 20 // Aligned hashes linking two queries when unknown.min_aligned_hashes is not set
+	DEFAULT_UNKNOWN_MIN_OCCURRENCES    = 2  // Smallest cluster listed when unknown.min_occurrences is not set
+)
+
+// UnknownCluster groups unknown queries that match each other, such as every
+// airing of the same unidentified jingle
+type UnknownCluster struct {
+	ID      int                     // ID of the earliest query, used to promote the cluster
+	Queries []database.UnknownQuery // The queries of the cluster in chronological order
+}
+
+// ClusterUnknown matches the stored unknown queries against each other and
+// groups the ones that share aligned audio. Only the queries sharing enough
+// hashes to align unknown.min_aligned_hashes of them are compared, see
+// unknownCandidates. Queries hashed with an older version or another profile
+// are left out.
+//
+// Returns:
+//   - The clusters with at least unknown.min_occurrences queries, the most
+//     frequent first.
+//   - An error if the stored queries could not be read.
+func (e *Eureka) ClusterUnknown() ([]UnknownCluster, error) {
+	queries, err := e.database.GetUnknownQueries(fingerprint.HASH_VERSION, e.Config.Config.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading unknown queries: %v", err)
+	}
+
+	minHashes := e.Config.Unknown.MinAlignedHashes
+	if minHashes <= 0 {
+		minHashes = DEFAULT_UNKNOWN_MIN_ALIGNED_HASHES
+	}
+
+	parent := make([]int, len(queries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i, candidates := range unknownCandidates(queries, minHashes) {
+		for _, j := range candidates {
+			if find(i) == find(j) {
+				continue
+			}
+			comparison := CompareFingerprints(queries[i].Fingerprints, queries[j].Fingerprints)
+			if comparison.AlignedHashes >= minHashes && comparison.Similarity >= e.Config.Unknown.MinSimilarity {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := make(map[int][]database.UnknownQuery)
+	for i, query := range queries {
+		groups[find(i)] = append(groups[find(i)], query)
+	}
+
+	minOccurrences := e.Config.Unknown.MinOccurrences
+	if minOccurrences <= 0 {
+		minOccurrences = DEFAULT_UNKNOWN_MIN_OCCURRENCES
+	}
+
+	var clusters []UnknownCluster
+	for _, group := range groups {
+		if len(group) < minOccurrences {
+			continue
+		}
+		clusters = append(clusters, UnknownCluster{ID: group[0].ID, Queries: group})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Queries) != len(clusters[j].Queries) {
+			return len(clusters[i].Queries) > len(clusters[j].Queries)
+		}
+		return clusters[i].ID < clusters[j].ID
+	})
+	logger.Info(fmt.Sprintf("Found %d recurring clusters in %d unknown queries", len(clusters), len(queries)))
+
+	return clusters, nil
+}
+
+// PromoteCluster stores a cluster of unknown queries as a catalogue song. The
+// query with the most fingerprints represents the cluster, and the queries of
+// the cluster are removed from the unknown store once the song is saved.
+//
+// Parameters:
+//   - clusterID: The ID of the cluster, as listed by ClusterUnknown.
+//   - songName: The name of the new song.
+//   - artistName: The artist of the new song, may be empty.
+//
+// Returns:
+//   - An error if the cluster does not exist or the song could not be stored.
+func (e *Eureka) PromoteCluster(clusterID int, songName string, artistName string) error {
+	if songName == "" {
+		return fmt.Errorf("a song name is needed to promote a cluster")
+	}
+
+	clusters, err := e.ClusterUnknown()
+	if err != nil {
+		return err
+	}
+
+	var cluster *UnknownCluster
+	for i := range clusters {
+		if clusters[i].ID == clusterID {
+			cluster = &clusters[i]
+			break
+		}
+	}
+	if cluster == nil {
+		return fmt.Errorf("unknown cluster %d not found", clusterID)
+	}
+
+	representative := cluster.Queries[0]
+	for _, query := range cluster.Queries[1:] {
+		if len(query.Fingerprints) > len(representative.Fingerprints) {
+			representative = query
+		}
+	}
+
+	// There is no source file to hash, the cluster ID identifies the audio
+	hash := sha1.Sum([]byte("unknown:" + strconv.Itoa(cluster.ID)))
+	if err := e.storeSong(songName, artistName, hex.EncodeToString(hash[:]), representative.Fingerprints); err != nil {
+		return err
+	}
+
+	ids := make([]int, len(cluster.Queries))
+	for i, query := range cluster.Queries {
+		ids[i] = query.ID
+	}
+	if err := e.database.DeleteUnknownQueries(ids); err != nil {
+		return fmt.Errorf("error removing promoted queries: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Promoted cluster %d with %d occurrences to %s", cluster.ID, len(cluster.Queries), songName))
+
+	return nil
+}
+
+// recordUnknown stores the fingerprints of a query that matched no song in
+// the database when unknown.record is set.
+//
+// Parameters:
+//   - fingerprints: The fingerprints of the query.
+//   - source: The file or stream the query was heard in.
+//   - position: The start of the query in its source, in seconds.
+func (e *Eureka) recordUnknown(fingerprints []fingerprint.Fingerprint, source string, position float64) error {
+	if !e.Config.Unknown.Record || len(fingerprints) == 0 {
+		return nil
+	}
+
+	query := database.UnknownQuery{
+		Source:       source,
+		Position:     position,
+		HashVersion:  fingerprint.HASH_VERSION,
+		Profile:      e.Config.Config.Profile,
+		RecordedAt:   time.Now(),
+		Fingerprints: fingerprints,
+	}
+
+	id, err := e.database.InsertUnknownQuery(query)
+	if err != nil {
+		return fmt.Errorf("error recording unknown query: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Recorded unknown query %d from %s at %.1fs", id, source, position))
+
+	return nil
+}
+
+// recordUnknownSamples fingerprints samples and records them as an unknown query
+func (e *Eureka) recordUnknownSamples(samples []float64, sampleRate int, source string, position float64) error {
+	if !e.Config.Unknown.Record {
+		return nil
+	}
+
+	fingerprints, err := fingerprint.FingerprintSamples(samples, sampleRate, e.fingerprintProfile())
+	if err != nil {
+		return err
+	}
+
+	return e.recordUnknown(fingerprints, source, position)
+}
+
+// unmatched reports whether results are too weak to identify the query, using
+// the same threshold as the monitor
+func (e *Eureka) unmatched(results []Result) bool {
+	return len(results) == 0 || results[0].AlignedHashes < e.thresholds().monitorMinAlignedHashes
+}
+
+// unknownCandidates returns, for every unknown query, the later queries that
+// share enough hashes to possibly align minHashes of them. An inverted index
+// from hashes to the queries holding them finds these without comparing
+// every pair of queries.
+func unknownCandidates(queries []database.UnknownQuery, minHashes int) [][]int {
+	counts := make([]map[string]int, len(queries))
+	index := make(map[string][]int)
+	for i, query := range queries {
+		counts[i] = make(map[string]int)
+		for _, fp := range query.Fingerprints {
+			counts[i][fp.Hash]++
+		}
+		for hash := range counts[i] {
+			index[hash] = append(index[hash], i)
+		}
+	}
+
+	candidates := make([][]int, len(queries))
+	for i := range queries {
+		// Every pair of fingerprints sharing a hash can align at most once
+		shared := make(map[int]int)
+		for hash, count := range counts[i] {
+			for _, j := range index[hash] {
+				if j > i {
+					shared[j] += count * counts[j][hash]
+				}
+			}
+		}
+		for j, hits := range shared {
+			if hits >= minHashes {
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+		sort.Ints(candidates[i])
+	}
+
+	return candidates
+}
@@ -0,0 +1,152 @@
+package eureka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/media-luna/eureka/internal/database"
+	"github.com/media-luna/eureka/internal/fingerprint"
+)
+
+// testUnknownQuery builds an unknown query of the default profile
+func testUnknownQuery(id int, fingerprints []fingerprint.Fingerprint) database.UnknownQuery {
+	return database.UnknownQuery{ID: id, HashVersion: fingerprint.HASH_VERSION, Profile: PROFILE_DEFAULT, Fingerprints: fingerprints}
+}
+
+func TestUnknownCandidates(t *testing.T) {
+	jingle := testHashes("j", 30, 0)
+	queries := []database.UnknownQuery{
+		testUnknownQuery(1, jingle),
+		testUnknownQuery(2, testHashes("x", 30, 0)),
+		testUnknownQuery(3, testHashes("j", 30, 5000)),
+		testUnknownQuery(4, testHashes("j", 10, 0)),
+	}
+
+	tests := []struct {
+		name      string
+		minHashes int
+		want      [][]int
+	}{
+		{name: "later queries sharing enough hashes", minHashes: 20, want: [][]int{{2}, nil, nil, nil}},
+		{name: "lower threshold", minHashes: 10, want: [][]int{{2, 3}, nil, {3}, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unknownCandidates(queries, tt.minHashes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unknownCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterUnknown(t *testing.T) {
+	jingle := testHashes("j", 30, 0)
+	outdated := testUnknownQuery(5, jingle)
+	outdated.HashVersion = fingerprint.HASH_VERSION - 1
+
+	tests := []struct {
+		name           string
+		queries        []database.UnknownQuery
+		minOccurrences int
+		want           [][]int // IDs of the queries of each cluster
+	}{
+		{
+			name: "airings of the same jingle",
+			queries: []database.UnknownQuery{
+				testUnknownQuery(1, jingle),
+				testUnknownQuery(2, testHashes("x", 30, 0)),
+				testUnknownQuery(3, testHashes("j", 30, 5000)),
+				testUnknownQuery(4, testHashes("j", 30, 9000)),
+			},
+			want: [][]int{{1, 3, 4}},
+		},
+		{
+			name: "most frequent cluster first",
+			queries: []database.UnknownQuery{
+				testUnknownQuery(1, testHashes("x", 30, 0)),
+				testUnknownQuery(2, jingle),
+				testUnknownQuery(3, testHashes("x", 30, 2000)),
+				testUnknownQuery(4, jingle),
+				testUnknownQuery(6, jingle),
+			},
+			want: [][]int{{2, 4, 6}, {1, 3}},
+		},
+		{
+			name:           "clusters below min occurrences left out",
+			queries:        []database.UnknownQuery{testUnknownQuery(1, jingle), testUnknownQuery(2, jingle)},
+			minOccurrences: 3,
+		},
+		{
+			name:    "outdated queries left out",
+			queries: []database.UnknownQuery{testUnknownQuery(1, jingle), outdated},
+		},
+		{
+			name:    "too few aligned hashes",
+			queries: []database.UnknownQuery{testUnknownQuery(1, jingle), testUnknownQuery(2, testHashes("j", 10, 0))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB()
+			db.unknown = tt.queries
+			e := newTestEureka(db)
+			e.Config.Unknown.MinOccurrences = tt.minOccurrences
+
+			clusters, err := e.ClusterUnknown()
+			if err != nil {
+				t.Fatalf("ClusterUnknown() error = %v", err)
+			}
+
+			var got [][]int
+			for _, cluster := range clusters {
+				var ids []int
+				for _, query := range cluster.Queries {
+					ids = append(ids, query.ID)
+				}
+				if cluster.ID != ids[0] {
+					t.Errorf("cluster %v has ID %d, want its earliest query", ids, cluster.ID)
+				}
+				got = append(got, ids)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClusterUnknown() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordUnknown(t *testing.T) {
+	db := newFakeDB()
+	db.addSong(1, "song", testHashes("s", 40, 0))
+	query := testHashes("s", 40, 0)
+
+	tests := []struct {
+		name        string
+		query       []fingerprint.Fingerprint
+		minAligned  int
+		wantUnknown bool
+	}{
+		{name: "no match", query: testHashes("x", 40, 0), wantUnknown: true},
+		{name: "weak match below the default threshold", query: query[:DEFAULT_MONITOR_MIN_ALIGNED_HASHES-1], wantUnknown: true},
+		{name: "match", query: query[:DEFAULT_MONITOR_MIN_ALIGNED_HASHES]},
+		{name: "configured threshold", query: query[:20], minAligned: 30, wantUnknown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.unknown = nil
+			e := newTestEureka(db)
+			e.Config.Unknown.Record = true
+			e.Config.Monitor.MinAlignedHashes = tt.minAligned
+
+			if _, err := e.RecognizeFingerprints(tt.query, PROFILE_DEFAULT); err != nil {
+				t.Fatalf("RecognizeFingerprints() error = %v", err)
+			}
+			if recorded := len(db.unknown) > 0; recorded != tt.wantUnknown {
+				t.Errorf("recorded as unknown = %v, want %v", recorded, tt.wantUnknown)
+			}
+		})
+	}
+}
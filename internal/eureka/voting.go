@@ -0,0 +1,170 @@
+package eureka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/media-luna/eureka/utils/logger"
+)
+
+const (
+	DEFAULT_VOTE_WINDOW_SECONDS = 3.0 // Sub-window length used when recognition.vote_window_seconds is not set
+	DEFAULT_VOTE_HOP_SECONDS    = 1.5 // Step between sub-windows used when recognition.vote_hop_seconds is not set
+	DEFAULT_VOTE_MIN_WINDOWS    = 3   // Fewest agreeing sub-windows used when recognition.vote_min_windows is not set
+	VOTE_OFFSET_TOLERANCE_MS    = 100 // Max distance between the offsets of sub-windows voting together, in milliseconds
+)
+
+// vote is the winner of a single sub-window, with its offset brought back to
+// the start of the query
+type vote struct {
+	result Result
+	anchor int
+}
+
+// voteKey identifies a song across catalogues, whose song IDs may collide
+type voteKey struct {
+	catalogue string
+	songID    int
+}
+
+// recognizeVoting splits the query into overlapping sub-windows, recognizes
+// each one on its own and only accepts songs that win a majority of the
+// sub-windows with coherent offsets, see votesNeeded. A noisy clip often
+// produces one spurious candidate that wins a single sub-window, while the
+// true song wins most of them at the same position. A query too short for
+// recognition.vote_min_windows sub-windows cannot be voted on and is
+// recognized as a whole instead.
+//
+// The aligned hashes, score and confidences of an accepted song are those of
+// its strongest agreeing sub-window, so they are measured against the hashes
+// of that sub-window rather than the whole query.
+//
+// Parameters:
+//   - samples: Mono audio samples scaled to the range [-1, 1].
+//   - sampleRate: The sample rate of the audio.
+//
+// Returns:
+//   - The accepted songs ordered by the number of agreeing sub-windows.
+//   - An error if a sub-window could not be recognized.
+func (e *Eureka) recognizeVoting(samples []float64, sampleRate int) ([]Result, error) {
+	windowSeconds := e.voteWindowSeconds()
+	hopSeconds := e.Config.Recognition.VoteHopSeconds
+	if hopSeconds <= 0 {
+		hopSeconds = DEFAULT_VOTE_HOP_SECONDS
+	}
+
+	window := int(windowSeconds * float64(sampleRate))
+	hop := int(hopSeconds * float64(sampleRate))
+	starts := []int{0}
+	for start := hop; start+window <= len(samples); start += hop {
+		starts = append(starts, start)
+	}
+
+	if minWindows := e.voteMinWindows(); len(starts) < minWindows {
+		logger.Info(fmt.Sprintf("Query too short for %d sub-windows, recognizing it as a whole", minWindows))
+		return e.recognizeSamples(samples, sampleRate)
+	}
+
+	// Collect the winner of every sub-window
+	votes := make(map[voteKey][]vote)
+	for _, start := range starts {
+		end := start + window
+		if end > len(samples) {
+			end = len(samples)
+		}
+
+		results, err := e.recognizeSamples(samples[start:end], sampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("error recognizing sub-window at %.1fs: %v", float64(start)/float64(sampleRate), err)
+		}
+		if len(results) == 0 {
+			continue
+		}
+
+		winner := results[0]
+		anchor := winner.Offset - start*1000/sampleRate
+		key := voteKey{catalogue: winner.Catalogue, songID: winner.SongID}
+		votes[key] = append(votes[key], vote{result: winner, anchor: anchor})
+	}
+
+	required := e.votesNeeded(len(starts))
+	var results []Result
+	for _, songVotes := range votes {
+		agreeing := coherentVotes(songVotes)
+		if len(agreeing) < required {
+			continue
+		}
+
+		// Report the strongest agreeing sub-window, placed at the query start
+		best := agreeing[0]
+		for _, v := range agreeing[1:] {
+			if v.result.Score > best.result.Score {
+				best = v
+			}
+		}
+		result := best.result
+		result.Offset = best.anchor
+		result.OffsetSeconds = float64(best.anchor) / 1000
+		result.Windows = len(starts)
+		result.WindowsAgreed = len(agreeing)
+		results = append(results, result)
+	}
+	logger.Info(fmt.Sprintf("Accepted %d songs from %d sub-windows", len(results), len(starts)))
+	if err := e.setSections(context.Background(), results); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].WindowsAgreed != results[j].WindowsAgreed {
+			return results[i].WindowsAgreed > results[j].WindowsAgreed
+		}
+		return results[i].Score > results[j].Score
+	})
+
+	return limitResults(results, e.topResults()), nil
+}
+
+// coherentVotes returns the largest group of votes whose anchors lie within
+// VOTE_OFFSET_TOLERANCE_MS of each other
+func coherentVotes(votes []vote) []vote {
+	sorted := make([]vote, len(votes))
+	copy(sorted, votes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].anchor < sorted[j].anchor })
+
+	var best []vote
+	for i := range sorted {
+		j := i
+		for j < len(sorted) && sorted[j].anchor-sorted[i].anchor <= VOTE_OFFSET_TOLERANCE_MS {
+			j++
+		}
+		if j-i > len(best) {
+			best = sorted[i:j]
+		}
+	}
+
+	return best
+}
+
+// voteMinWindows returns the fewest agreeing sub-windows a song needs
+func (e *Eureka) voteMinWindows() int {
+	if windows := e.Config.Recognition.VoteMinWindows; windows > 0 {
+		return windows
+	}
+	return DEFAULT_VOTE_MIN_WINDOWS
+}
+
+// votesNeeded returns how many of the sub-windows that ran a song must win
+// with coherent offsets to be accepted: more than half of them, and never
+// fewer than voteMinWindows
+func (e *Eureka) votesNeeded(windows int) int {
+	return max(windows/2+1, e.voteMinWindows())
+}
+
+// voteWindowSeconds returns the length of the sub-windows of a voting query
+func (e *Eureka) voteWindowSeconds() float64 {
+	if seconds := e.Config.Recognition.VoteWindowSeconds; seconds > 0 {
+		return seconds
+	}
+	return DEFAULT_VOTE_WINDOW_SECONDS
+}
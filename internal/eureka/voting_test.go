@@ -0,0 +1,64 @@
+package eureka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoherentVotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		anchors []int
+		want    []int // Anchors of the agreeing votes, in ascending order
+	}{
+		{name: "no votes"},
+		{name: "single vote", anchors: []int{1000}, want: []int{1000}},
+		{name: "largest group wins", anchors: []int{500, 0, 560, 50, 90}, want: []int{0, 50, 90}},
+		{name: "tolerance is inclusive", anchors: []int{0, VOTE_OFFSET_TOLERANCE_MS}, want: []int{0, VOTE_OFFSET_TOLERANCE_MS}},
+		{name: "beyond tolerance", anchors: []int{0, VOTE_OFFSET_TOLERANCE_MS + 1}, want: []int{0}},
+		{name: "later group larger", anchors: []int{0, 1000, 1050, 1100}, want: []int{1000, 1050, 1100}},
+		{name: "negative anchors", anchors: []int{-80, -20, 3000}, want: []int{-80, -20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			votes := make([]vote, len(tt.anchors))
+			for i, anchor := range tt.anchors {
+				votes[i] = vote{anchor: anchor}
+			}
+
+			var got []int
+			for _, v := range coherentVotes(votes) {
+				got = append(got, v.anchor)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got anchors %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVotesNeeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		minWindows int
+		windows    int
+		want       int
+	}{
+		{name: "default minimum", windows: 3, want: DEFAULT_VOTE_MIN_WINDOWS},
+		{name: "minimum above the majority", minWindows: 4, windows: 5, want: 4},
+		{name: "majority of odd windows", minWindows: 2, windows: 7, want: 4},
+		{name: "majority of even windows", minWindows: 2, windows: 8, want: 5},
+		{name: "single window", minWindows: 1, windows: 1, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Eureka{}
+			e.Config.Recognition.VoteMinWindows = tt.minWindows
+			if got := e.votesNeeded(tt.windows); got != tt.want {
+				t.Errorf("votesNeeded(%d) = %d, want %d", tt.windows, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package fingerprint
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	FINGERPRINT_FORMAT_VERSION = 2 // Version of the hashing scheme, bumped whenever GenerateFingerprints or FingerprintSet changes its output
+)
+
+// FingerprintSet is the exchange format for fingerprints produced outside of
+// the server, such as on a client that only sends hashes and offsets
+type FingerprintSet struct {
+	Version      int           `json:"version"`
+	Profile      string        `json:"profile"` // Name of the fingerprinting profile the fingerprints were made with
+	Fingerprints []Fingerprint `json:"fingerprints"`
+}
+
+// WriteFingerprints encodes fingerprints as a JSON FingerprintSet.
+//
+// Parameters:
+//   - w: The writer receiving the JSON document.
+//   - profile: The name of the profile the fingerprints were made with.
+//   - fingerprints: The fingerprints to encode.
+//
+// Returns:
+//   - An error if encoding failed.
+func WriteFingerprints(w io.Writer, profile string, fingerprints []Fingerprint) error {
+	set := FingerprintSet{
+		Version:      FINGERPRINT_FORMAT_VERSION,
+		Profile:      profile,
+		Fingerprints: fingerprints,
+	}
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(set); err != nil {
+		return fmt.Errorf("error encoding fingerprints: %v", err)
+	}
+
+	return nil
+}
+
+// ReadFingerprints decodes a JSON FingerprintSet and checks that it was
+// produced with the same hashing scheme as this build. The profile is left
+// for the caller to check against the catalogue it searches.
+//
+// Parameters:
+//   - r: The reader providing the JSON document.
+//
+// Returns:
+//   - The decoded set.
+//   - An error if the document is invalid, names no profile or its version
+//     does not match.
+func ReadFingerprints(r io.Reader) (FingerprintSet, error) {
+	var set FingerprintSet
+	if err := json.NewDecoder(r).Decode(&set); err != nil {
+		return set, fmt.Errorf("error decoding fingerprints: %v", err)
+	}
+
+	if set.Version != FINGERPRINT_FORMAT_VERSION {
+		return set, fmt.Errorf("unsupported fingerprint format version %d, expected %d", set.Version, FINGERPRINT_FORMAT_VERSION)
+	}
+	if set.Profile == "" {
+		return set, fmt.Errorf("fingerprints name no profile")
+	}
+
+	for i, fp := range set.Fingerprints {
+		if _, err := hex.DecodeString(fp.Hash); err != nil || len(fp.Hash) != FINGERPRINT_REDUCTION {
+			return set, fmt.Errorf("invalid hash at index %d: expected %d hex characters", i, FINGERPRINT_REDUCTION)
+		}
+		if fp.Offset < 0 {
+			return set, fmt.Errorf("invalid offset at index %d: must not be negative", i)
+		}
+	}
+
+	return set, nil
+}
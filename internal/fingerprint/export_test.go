@@ -0,0 +1,71 @@
+package fingerprint
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadFingerprints(t *testing.T) {
+	const hash = "0123456789abcdef0123"
+
+	tests := []struct {
+		name    string
+		doc     string
+		want    int // Number of fingerprints
+		wantErr bool
+	}{
+		{name: "valid", doc: fmt.Sprintf(`{"version": %d, "profile": "default", "fingerprints": [{"hash": "%s", "offset": 0}, {"hash": "%s", "offset": 46}]}`, FINGERPRINT_FORMAT_VERSION, hash, hash), want: 2},
+		{name: "empty", doc: fmt.Sprintf(`{"version": %d, "profile": "short", "fingerprints": []}`, FINGERPRINT_FORMAT_VERSION)},
+		{name: "older version", doc: fmt.Sprintf(`{"version": 1, "profile": "default", "fingerprints": [{"hash": "%s", "offset": 0}]}`, hash), wantErr: true},
+		{name: "no profile", doc: fmt.Sprintf(`{"version": %d, "fingerprints": [{"hash": "%s", "offset": 0}]}`, FINGERPRINT_FORMAT_VERSION, hash), wantErr: true},
+		{name: "short hash", doc: fmt.Sprintf(`{"version": %d, "profile": "default", "fingerprints": [{"hash": "0123", "offset": 0}]}`, FINGERPRINT_FORMAT_VERSION), wantErr: true},
+		{name: "hash not hex", doc: fmt.Sprintf(`{"version": %d, "profile": "default", "fingerprints": [{"hash": "%s", "offset": 0}]}`, FINGERPRINT_FORMAT_VERSION, strings.Repeat("z", FINGERPRINT_REDUCTION)), wantErr: true},
+		{name: "negative offset", doc: fmt.Sprintf(`{"version": %d, "profile": "default", "fingerprints": [{"hash": "%s", "offset": -1}]}`, FINGERPRINT_FORMAT_VERSION, hash), wantErr: true},
+		{name: "invalid JSON", doc: `{"version": `, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ReadFingerprints(strings.NewReader(tt.doc))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", set)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(set.Fingerprints) != tt.want {
+				t.Errorf("got %d fingerprints, want %d", len(set.Fingerprints), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFingerprintsRoundTrip(t *testing.T) {
+	fingerprints := []Fingerprint{{Hash: "0123456789abcdef0123", Offset: 12}, {Hash: "abcdef0123456789abcd", Offset: 58}}
+
+	var buf bytes.Buffer
+	if err := WriteFingerprints(&buf, "short", fingerprints); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	set, err := ReadFingerprints(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if set.Profile != "short" {
+		t.Errorf("got profile %q, want %q", set.Profile, "short")
+	}
+	if len(set.Fingerprints) != len(fingerprints) {
+		t.Fatalf("got %d fingerprints, want %d", len(set.Fingerprints), len(fingerprints))
+	}
+	for i, fp := range set.Fingerprints {
+		if fp != fingerprints[i] {
+			t.Errorf("fingerprint %d is %+v, want %+v", i, fp, fingerprints[i])
+		}
+	}
+}
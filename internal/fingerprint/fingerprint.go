@@ -2,9 +2,12 @@ package fingerprint
 
 import (
 	"crypto/sha1"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/cmplx"
 	"os"
 )
@@ -18,13 +21,24 @@ const (
 	DOWNSAMPLE_RATIO       = 1    // Downsampling ratio for the audio samples(devide the amount of samples by N)
 	MIN_WAV_BYTES          = 44   // Minimum number of bytes required for a valid WAV file
 	HEADER_BITS_PER_SAMPLE = 16   // Number of bits per sample in the WAV file header
+	FINGERPRINT_REDUCTION  = 20   // Number of hex characters of the SHA1 digest kept as the stored hash
+	MAX_JITTER_FREQ_BINS   = 2    // Max number of neighbouring frequency bins hashed by query expansion
+	MAX_JITTER_TIME_FRAMES = 1    // Max number of neighbouring time delta frames hashed by query expansion
+	HASH_VERSION           = 2    // Version of the hashing scheme of hashPeaks, stored with every song
 )
 
 // Fingerprint represents a single audio fingerprint
 type Fingerprint struct {
-	Hash   string
-	SongID int
-	Offset int
+	Hash   string `json:"hash"`
+	SongID int    `json:"song_id,omitempty"`
+	Offset int    `json:"offset"`
+
+	// Pair numbers the pair of peaks an expanded hash was generated from,
+	// starting at 1 for each call to ExpandFingerprints, so that the
+	// expansions of a pair can be told apart from other pairs anchored at the
+	// same offset. It is 0 for hashes that were not expanded and when
+	// unknown, such as for stored or imported fingerprints.
+	Pair int `json:"-"`
 }
 
 // Fingerprints
@@ -38,6 +52,7 @@ type Peak struct {
 	TimeMS    float64
 	Magnitude float64
 	Freq      complex128
+	Bin       int // Frequency bin index of the peak in the spectrogram frame
 }
 
 // ExtractPeaks identifies and extracts peaks from a given spectrogram based on a specified threshold.
@@ -45,22 +60,23 @@ type Peak struct {
 //
 // Parameters:
 //   - spectrogram: A 2D slice of complex128 values representing the spectrogram data.
-//   - threshold: A float64 value representing the minimum magnitude required for a peak.
+//   - sampleRate: The sample rate of the audio the spectrogram was computed from.
+//   - profile: The profile whose PeakThreshold is the minimum magnitude required for a peak.
 //
 // Returns:
 //   - A slice of Peak structs, each representing a detected peak with its time and frequency.
-func PickPeaks(spectrogram [][]complex128, sampleRate int) []Peak {
+func PickPeaks(spectrogram [][]complex128, sampleRate int, profile Profile) []Peak {
 	magnitudes := getMagnitudes(spectrogram)
 	var peaks []Peak
 	freqMap := make(map[string]bool)
 
 	for t, frame := range magnitudes {
 		for f, magnitude := range frame {
-			if magnitude > PEAK_THRESHOLD && isLocalPeak(magnitudes, t, f) {
+			if magnitude > profile.PeakThreshold && isLocalPeak(magnitudes, t, f) {
 				freqStr := fmt.Sprintf("%.10f", real(spectrogram[t][f]))
 				if _, exists := freqMap[freqStr]; !exists {
 					timeMS := float64(t) * float64(WINDOW_SIZE) / float64(sampleRate) * 1000
-					peaks = append(peaks, Peak{Time: float64(t), TimeMS: timeMS, Freq: spectrogram[t][f], Magnitude: magnitude})
+					peaks = append(peaks, Peak{Time: float64(t), TimeMS: timeMS, Freq: spectrogram[t][f], Magnitude: magnitude, Bin: f})
 					freqMap[freqStr] = true
 				}
 			}
@@ -139,37 +155,242 @@ func CalculateFileHash(filePath string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// GenerateFingerprints generates fingerprints from spectrogram peaks
-func GenerateFingerprints(peaks []Peak) []Fingerprint {
+// FingerprintSamples runs mono samples through the spectrogram, peak picking
+// and fingerprinting stages entirely in memory. The samples are copied first
+// so the caller's slice is left untouched.
+//
+// Parameters:
+//   - samples: Mono audio samples scaled to the range [-1, 1].
+//   - sampleRate: The sample rate of the audio.
+//   - profile: The peak picking and pairing settings.
+//
+// Returns:
+//   - The fingerprints of the audio.
+//   - An error if the spectrogram could not be computed.
+func FingerprintSamples(samples []float64, sampleRate int, profile Profile) ([]Fingerprint, error) {
+	peaks, err := SamplesToPeaks(samples, sampleRate, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenerateFingerprints(peaks, profile), nil
+}
+
+// SamplesToPeaks runs mono samples through the spectrogram and peak picking
+// stages entirely in memory, leaving the caller's slice untouched.
+func SamplesToPeaks(samples []float64, sampleRate int, profile Profile) ([]Peak, error) {
+	if sampleRate <= 0 {
+		return nil, errors.New("sample rate must be positive")
+	}
+
+	buf := make([]float64, len(samples))
+	copy(buf, samples)
+
+	spectrogram, err := SamplesToSpectrogram(buf, sampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("error creating spectrogram: %v", err)
+	}
+
+	return PickPeaks(spectrogram, sampleRate, profile), nil
+}
+
+// StretchPeaks returns a copy of peaks with their times multiplied by factor,
+// as if the audio had been played factor times slower. Stretching the peaks of
+// a query played factor times faster than a song brings them back to the
+// song's timing. Times are rounded to whole spectrogram frames so the time
+// deltas of the generated hashes fall on the same grid as those of unmodified
+// audio.
+//
+// Parameters:
+//   - peaks: The peaks to stretch.
+//   - factor: The multiplier applied to the peak times, above 1 spreads them out, 1 leaves them unchanged.
+//
+// Returns:
+//   - The stretched peaks.
+func StretchPeaks(peaks []Peak, factor float64) []Peak {
+	stretched := make([]Peak, len(peaks))
+	for i, peak := range peaks {
+		stretched[i] = peak
+		if peak.Time == 0 {
+			continue
+		}
+		frame := math.Round(peak.Time * factor)
+		stretched[i].TimeMS = peak.TimeMS * frame / peak.Time
+		stretched[i].Time = frame
+	}
+
+	return stretched
+}
+
+// ShiftPeaks returns a copy of peaks with their frequencies shifted by the
+// given number of semitones, as if the audio had been pitch shifted. Bins in
+// the mirrored upper half of the frame are shifted around their mirror so
+// they stay consistent with the lower half.
+//
+// Parameters:
+//   - peaks: The peaks to shift.
+//   - semitones: The shift applied to the peaks, 0 leaves them unchanged.
+//
+// Returns:
+//   - The shifted peaks.
+func ShiftPeaks(peaks []Peak, semitones float64) []Peak {
+	ratio := math.Pow(2, semitones/12)
+	half := WINDOW_SIZE / 2
+
+	shifted := make([]Peak, len(peaks))
+	for i, peak := range peaks {
+		shifted[i] = peak
+		if peak.Bin <= half {
+			shifted[i].Bin = int(math.Round(float64(peak.Bin) * ratio))
+		} else {
+			shifted[i].Bin = WINDOW_SIZE - int(math.Round(float64(WINDOW_SIZE-peak.Bin)*ratio))
+		}
+	}
+
+	return shifted
+}
+
+// CalculateSamplesHash generates a SHA1 hash of in-memory samples, used in
+// place of CalculateFileHash when audio does not come from a file
+func CalculateSamplesHash(samples []float64) string {
+	h := sha1.New()
+	buf := make([]byte, 8)
+	for _, sample := range samples {
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(sample))
+		h.Write(buf)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GenerateFingerprints generates fingerprints from spectrogram peaks, pairing
+// each peak with the following ones within the target zone of the profile
+func GenerateFingerprints(peaks []Peak, profile Profile) []Fingerprint {
+	return ExpandFingerprints(peaks, profile, 0, 0)
+}
+
+// ExpandFingerprints generates fingerprints from spectrogram peaks and, for
+// every pair of peaks, also emits the hashes of the neighbouring frequency bins
+// and time deltas. Looking up the expanded hashes recovers pairs whose peaks
+// were quantized one bin or one frame away from the stored ones, which is
+// common on noisy recordings. The radii are capped at MAX_JITTER_FREQ_BINS and
+// MAX_JITTER_TIME_FRAMES since the number of hashes grows with their cube.
+//
+// Parameters:
+//   - peaks: The spectrogram peaks ordered by time.
+//   - profile: The pairing settings.
+//   - freqRadius: How many bins above and below each peak are also hashed.
+//   - timeRadius: How many frames before and after each time delta are also hashed.
+//
+// Returns:
+//   - The fingerprints, all expansions of a pair sharing the anchor offset
+//     and the pair number.
+func ExpandFingerprints(peaks []Peak, profile Profile, freqRadius int, timeRadius int) []Fingerprint {
+	return pairPeaks(peaks, 0, profile, freqRadius, timeRadius)
+}
+
+// ExtendFingerprints generates the fingerprints of a stream one chunk at a
+// time. The peaks of a new chunk are paired with each other and with the
+// last peaks of the previous chunks, so that pairs crossing a chunk boundary
+// are not lost and the stream gets the same fingerprints as if it had been
+// fingerprinted at once.
+//
+// Parameters:
+//   - carried: The peaks returned by the previous call, nil for the first chunk.
+//   - peaks: The peaks of the new chunk, ordered by time.
+//   - profile: The pairing settings.
+//
+// Returns:
+//   - The fingerprints of the pairs whose target is one of the new peaks.
+//   - The peaks to carry over to the next chunk.
+func ExtendFingerprints(carried []Peak, peaks []Peak, profile Profile) ([]Fingerprint, []Peak) {
+	all := append(carried[:len(carried):len(carried)], peaks...)
+	fingerprints := pairPeaks(all, len(carried), profile, 0, 0)
+
+	// Only the last FanValue-1 peaks can still be paired with a later peak
+	keep := min(len(all), max(profile.FanValue-1, 0))
+	return fingerprints, append([]Peak(nil), all[len(all)-keep:]...)
+}
+
+// pairPeaks pairs each peak with the following ones within the target zone
+// of the profile, skipping the pairs whose target comes before the peak at
+// index first, and hashes every pair with its jitter expansions
+func pairPeaks(peaks []Peak, first int, profile Profile, freqRadius int, timeRadius int) []Fingerprint {
+	freqRadius = clamp(freqRadius, 0, MAX_JITTER_FREQ_BINS)
+	timeRadius = clamp(timeRadius, 0, MAX_JITTER_TIME_FRAMES)
+	expanded := freqRadius > 0 || timeRadius > 0
+	minDelta := float64(profile.MinHashTimeDelta)
+	maxDelta := float64(profile.MaxHashTimeDelta)
+
 	var fingerprints []Fingerprint
+	pair := 0
 
 	// Fan out from each peak
 	for i, anchor := range peaks {
 		// Look at the next few peaks as target points
-		for j := i + 1; j < i+FAN_VALUE && j < len(peaks); j++ {
+		for j := max(i+1, first); j < i+profile.FanValue && j < len(peaks); j++ {
 			target := peaks[j]
 
 			// Create hash using frequency and time delta
 			timeDelta := target.TimeMS - anchor.TimeMS
-			if timeDelta <= MIN_HASH_TIME_DELTA || timeDelta > MAX_HASH_TIME_DELTA {
+			if timeDelta <= minDelta || timeDelta > maxDelta {
 				continue
 			}
+			frameMS := timeDelta / (target.Time - anchor.Time)
+			if expanded {
+				pair++
+			}
 
-			// Use real part of complex frequency as the frequency value
-			anchorFreq := int(real(anchor.Freq))
-			targetFreq := int(real(target.Freq))
-
-			hashStr := fmt.Sprintf("%d|%d|%d",
-				anchorFreq,
-				targetFreq,
-				int(timeDelta))
-
-			fingerprints = append(fingerprints, Fingerprint{
-				Hash:   hashStr,
-				Offset: int(anchor.TimeMS),
-			})
+			for dt := -timeRadius; dt <= timeRadius; dt++ {
+				delta := timeDelta + float64(dt)*frameMS
+				if delta <= minDelta || delta > maxDelta {
+					continue
+				}
+				for da := -freqRadius; da <= freqRadius; da++ {
+					for db := -freqRadius; db <= freqRadius; db++ {
+						if anchor.Bin+da < 0 || target.Bin+db < 0 {
+							continue
+						}
+						fingerprints = append(fingerprints, Fingerprint{
+							Hash:   hashPeaks(anchor.Bin+da, target.Bin+db, int(delta)),
+							Offset: int(anchor.TimeMS),
+							Pair:   pair,
+						})
+					}
+				}
+			}
 		}
 	}
 
 	return fingerprints
 }
+
+// clamp limits value to the range [min, max]
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// hashPeaks builds the stored hash for a pair of peaks. The frequency bins of
+// both peaks and the time between them are joined and digested with SHA1, and
+// only the first FINGERPRINT_REDUCTION hex characters are kept so the hash fits
+// the fixed size binary column of the fingerprints table.
+//
+// Frequency bins are used rather than the raw FFT values because the latter
+// depend on the phase of the frame, which differs between a reference and a
+// query that does not start on the same sample.
+//
+// Hash versions:
+//   - 1: The real parts of the FFT values of both peaks and the time between
+//     them. Songs stored with it never match queries hashed with a later
+//     version and must be fingerprinted again, see Eureka.Reingest.
+//   - 2: The frequency bins of both peaks and the time between them.
+func hashPeaks(anchorBin, targetBin, timeDelta int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d|%d|%d", anchorBin, targetBin, timeDelta)))
+	return hex.EncodeToString(sum[:])[:FINGERPRINT_REDUCTION]
+}
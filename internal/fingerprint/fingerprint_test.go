@@ -0,0 +1,161 @@
+package fingerprint
+
+import (
+	"math"
+	"testing"
+)
+
+// testFrameMS is the length of a spectrogram frame at 44.1kHz, in milliseconds
+const testFrameMS = 1000.0 * WINDOW_SIZE / 44100
+
+// testPeak builds a peak at the given frame and frequency bin
+func testPeak(frame int, bin int) Peak {
+	return Peak{Time: float64(frame), TimeMS: float64(frame) * testFrameMS, Bin: bin}
+}
+
+func TestStretchPeaks(t *testing.T) {
+	peaks := []Peak{testPeak(0, 10), testPeak(3, 20), testPeak(10, 30)}
+
+	tests := []struct {
+		name       string
+		factor     float64
+		wantFrames []float64
+	}{
+		{name: "unchanged", factor: 1, wantFrames: []float64{0, 3, 10}},
+		{name: "slower spreads peaks out", factor: 2, wantFrames: []float64{0, 6, 20}},
+		{name: "faster packs peaks together", factor: 0.5, wantFrames: []float64{0, 2, 5}},
+		{name: "rounded to whole frames", factor: 1.1, wantFrames: []float64{0, 3, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stretched := StretchPeaks(peaks, tt.factor)
+			if len(stretched) != len(peaks) {
+				t.Fatalf("got %d peaks, want %d", len(stretched), len(peaks))
+			}
+			for i, peak := range stretched {
+				if peak.Time != tt.wantFrames[i] {
+					t.Errorf("peak %d at frame %v, want %v", i, peak.Time, tt.wantFrames[i])
+				}
+				if want := tt.wantFrames[i] * testFrameMS; math.Abs(peak.TimeMS-want) > 1e-9 {
+					t.Errorf("peak %d at %vms, want %vms", i, peak.TimeMS, want)
+				}
+				if peak.Bin != peaks[i].Bin {
+					t.Errorf("peak %d moved to bin %d, want %d", i, peak.Bin, peaks[i].Bin)
+				}
+			}
+		})
+	}
+
+	if peaks[1].Time != 3 {
+		t.Errorf("StretchPeaks modified its input")
+	}
+}
+
+func TestShiftPeaks(t *testing.T) {
+	peaks := []Peak{testPeak(0, 100), testPeak(1, 10), testPeak(2, WINDOW_SIZE-100)}
+
+	tests := []struct {
+		name      string
+		semitones float64
+		wantBins  []int
+	}{
+		{name: "unchanged", semitones: 0, wantBins: []int{100, 10, WINDOW_SIZE - 100}},
+		{name: "octave up", semitones: 12, wantBins: []int{200, 20, WINDOW_SIZE - 200}},
+		{name: "octave down", semitones: -12, wantBins: []int{50, 5, WINDOW_SIZE - 50}},
+		{name: "rounded to whole bins", semitones: 1, wantBins: []int{106, 11, WINDOW_SIZE - 106}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shifted := ShiftPeaks(peaks, tt.semitones)
+			if len(shifted) != len(peaks) {
+				t.Fatalf("got %d peaks, want %d", len(shifted), len(peaks))
+			}
+			for i, peak := range shifted {
+				if peak.Bin != tt.wantBins[i] {
+					t.Errorf("peak %d in bin %d, want %d", i, peak.Bin, tt.wantBins[i])
+				}
+				if peak.Time != peaks[i].Time {
+					t.Errorf("peak %d moved to frame %v, want %v", i, peak.Time, peaks[i].Time)
+				}
+			}
+		})
+	}
+
+	if peaks[0].Bin != 100 {
+		t.Errorf("ShiftPeaks modified its input")
+	}
+}
+
+func TestExpandFingerprints(t *testing.T) {
+	profile := Profile{FanValue: 5, MinHashTimeDelta: 0, MaxHashTimeDelta: 2000}
+	peaks := []Peak{testPeak(0, 10), testPeak(2, 20), testPeak(4, 30)}
+
+	tests := []struct {
+		name       string
+		peaks      []Peak
+		profile    Profile
+		freqRadius int
+		timeRadius int
+		want       int // Number of fingerprints
+		wantPairs  int // Number of distinct pair numbers, 0 when not expanded
+	}{
+		{name: "plain pairs", peaks: peaks, profile: profile, want: 3},
+		{name: "frequency jitter", peaks: peaks, profile: profile, freqRadius: 1, want: 3 * 9, wantPairs: 3},
+		{name: "time jitter", peaks: peaks, profile: profile, timeRadius: 1, want: 3 * 3, wantPairs: 3},
+		{name: "radii capped", peaks: peaks, profile: profile, freqRadius: 9, timeRadius: 9, want: 3 * 25 * 3, wantPairs: 3},
+		{name: "bins below zero skipped", peaks: []Peak{testPeak(0, 0), testPeak(2, 0)}, profile: profile, freqRadius: 1, want: 4, wantPairs: 1},
+		{name: "pairs beyond max delta", peaks: peaks[:2], profile: Profile{FanValue: 5, MaxHashTimeDelta: 30}},
+		{name: "fan value limits targets", peaks: peaks, profile: Profile{FanValue: 2, MaxHashTimeDelta: 2000}, want: 2},
+		{name: "no peaks", profile: profile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fingerprints := ExpandFingerprints(tt.peaks, tt.profile, tt.freqRadius, tt.timeRadius)
+			if len(fingerprints) != tt.want {
+				t.Fatalf("got %d fingerprints, want %d", len(fingerprints), tt.want)
+			}
+
+			pairs := make(map[int]int)
+			for _, fp := range fingerprints {
+				if len(fp.Hash) != FINGERPRINT_REDUCTION {
+					t.Errorf("hash %q has %d characters, want %d", fp.Hash, len(fp.Hash), FINGERPRINT_REDUCTION)
+				}
+				if tt.wantPairs == 0 && fp.Pair != 0 {
+					t.Errorf("unexpanded fingerprint has pair %d", fp.Pair)
+				}
+				if tt.wantPairs > 0 && fp.Pair == 0 {
+					t.Errorf("expanded fingerprint has no pair")
+				}
+				// All expansions of a pair share its anchor offset
+				if offset, ok := pairs[fp.Pair]; ok && tt.wantPairs > 0 && offset != fp.Offset {
+					t.Errorf("pair %d has offsets %d and %d", fp.Pair, offset, fp.Offset)
+				}
+				pairs[fp.Pair] = fp.Offset
+			}
+			if tt.wantPairs > 0 && len(pairs) != tt.wantPairs {
+				t.Errorf("got %d pairs, want %d", len(pairs), tt.wantPairs)
+			}
+		})
+	}
+}
+
+func TestExpandFingerprintsMatchesGenerate(t *testing.T) {
+	profile := Profile{FanValue: 5, MaxHashTimeDelta: 2000}
+	peaks := []Peak{testPeak(0, 10), testPeak(2, 20), testPeak(4, 30)}
+
+	plain := GenerateFingerprints(peaks, profile)
+	expanded := make(map[string]bool)
+	for _, fp := range ExpandFingerprints(peaks, profile, 1, 1) {
+		expanded[fp.Hash] = true
+	}
+
+	// Expansion looks up the unjittered hashes too
+	for _, fp := range plain {
+		if !expanded[fp.Hash] {
+			t.Errorf("hash %s at %dms missing from the expansion", fp.Hash, fp.Offset)
+		}
+	}
+}
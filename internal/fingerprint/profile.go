@@ -0,0 +1,35 @@
+package fingerprint
+
+const (
+	SHORT_PEAK_THRESHOLD      = 0.1  // Peak threshold of the short reference profile, lower to keep quieter peaks
+	SHORT_FAN_VALUE           = 15   // Target zone of the short reference profile, wider to pair each peak with more targets
+	SHORT_MIN_HASH_TIME_DELTA = 0    // Min milliseconds between 2 peaks paired by the short reference profile
+	SHORT_MAX_HASH_TIME_DELTA = 1000 // Max milliseconds between 2 peaks paired by the short reference profile, so pairs stay inside a short spot
+)
+
+// Profile holds the peak picking and pairing settings used to fingerprint
+// audio. References and queries must be fingerprinted with the same profile
+// for their hashes to match.
+type Profile struct {
+	PeakThreshold    float64 // Magnitude a local maximum must exceed to be a peak
+	FanValue         int     // Size of the target zone for peak pairing
+	MinHashTimeDelta int     // Min milliseconds between 2 paired peaks
+	MaxHashTimeDelta int     // Max milliseconds between 2 paired peaks
+}
+
+// DefaultProfile is the profile used for full length songs
+var DefaultProfile = Profile{
+	PeakThreshold:    PEAK_THRESHOLD,
+	FanValue:         FAN_VALUE,
+	MinHashTimeDelta: MIN_HASH_TIME_DELTA,
+	MaxHashTimeDelta: MAX_HASH_TIME_DELTA,
+}
+
+// ShortProfile produces a denser set of fingerprints, for references lasting
+// only a few seconds such as jingles, station IDs and ad spots
+var ShortProfile = Profile{
+	PeakThreshold:    SHORT_PEAK_THRESHOLD,
+	FanValue:         SHORT_FAN_VALUE,
+	MinHashTimeDelta: SHORT_MIN_HASH_TIME_DELTA,
+	MaxHashTimeDelta: SHORT_MAX_HASH_TIME_DELTA,
+}
@@ -22,6 +22,21 @@ func SamplesToSpectrogram(samples []float64, sampleRate int) ([][]complex128, er
 		samples[i] *= windowHamming[i]
 	}
 
+	return framesToSpectrogram(samples, sampleRate)
+}
+
+// SamplesToStreamSpectrogram computes the spectrogram of a chunk of a stream.
+// Unlike SamplesToSpectrogram it does not window the chunk as a whole, so a
+// frame has the same spectrum whichever chunk it is computed in, apart from
+// the first and last frame of the chunk, where the low-pass filter lacks
+// neighbouring samples.
+func SamplesToStreamSpectrogram(samples []float64, sampleRate int) ([][]complex128, error) {
+	return framesToSpectrogram(samples, sampleRate)
+}
+
+// framesToSpectrogram filters and downsamples the samples and computes the
+// FFT of every frame of WINDOW_SIZE samples
+func framesToSpectrogram(samples []float64, sampleRate int) ([][]complex128, error) {
 	// Apply low-pass filter (optional)
 	filteredSamples := lowPassFilter(samples, WINDOW_SIZE)
 
@@ -33,7 +48,7 @@ func SamplesToSpectrogram(samples []float64, sampleRate int) ([][]complex128, er
 
 	// Compute FFT
 	spectrogram := [][]complex128{}
-	for i := 0; i < len(downsampledSamples); i += WINDOW_SIZE {
+	for i := 0; i+WINDOW_SIZE <= len(downsampledSamples); i += WINDOW_SIZE {
 		frame := downsampledSamples[i : i + WINDOW_SIZE]
 		fftOut := fft.FFTReal(frame)
 		spectrogram = append(spectrogram, fftOut)
@@ -144,6 +144,33 @@ func parseWavHeader(headerData []byte) (*WavHeader, error) {
 	return &header, nil
 }
 
+// ReadWavHeader reads the header of a 16-bit PCM WAV file from r, leaving r
+// at the start of the audio data so that long recordings can be read in
+// chunks instead of being loaded at once.
+//
+// Parameters:
+//   - r: The reader positioned at the start of the WAV file.
+//
+// Returns:
+//   - A pointer to the parsed WavHeader.
+//   - An error if the header could not be read, is invalid, or the samples are not 16-bit.
+func ReadWavHeader(r io.Reader) (*WavHeader, error) {
+	headerData := make([]byte, MIN_WAV_BYTES)
+	if _, err := io.ReadFull(r, headerData); err != nil {
+		return nil, fmt.Errorf("error reading WAV header: %v", err)
+	}
+
+	header, err := parseWavHeader(headerData)
+	if err != nil {
+		return nil, err
+	}
+	if header.BitsPerSample != HEADER_BITS_PER_SAMPLE {
+		return nil, errors.New("unsupported bits per sample format")
+	}
+
+	return header, nil
+}
+
 // extractWavInfo extracts information from a WAV file header and data.
 // It returns a WavInfo struct containing the number of channels, sample rate,
 // data, and duration of the audio if the bits per sample is 16. If the bits per
@@ -210,6 +237,45 @@ func loadWAVFile(filename string) ([]byte, error) {
 	return data.Bytes(), nil
 }
 
+// DecodePCM16 converts raw interleaved 16-bit little-endian PCM audio into
+// mono float64 samples scaled to the range [-1, 1]. Multi-channel audio is
+// mixed down by averaging the channels of each frame.
+//
+// Parameters:
+//   - data: A byte slice containing interleaved 16-bit PCM audio.
+//   - channels: The number of interleaved channels in data.
+//
+// Returns:
+//   - A slice of mono float64 samples.
+//   - An error if the channel count is invalid or data does not hold whole frames.
+func DecodePCM16(data []byte, channels int) ([]float64, error) {
+	if channels <= 0 {
+		return nil, errors.New("channel count must be positive")
+	}
+	if len(data)%(2*channels) != 0 {
+		return nil, errors.New("invalid length, data must contain whole frames")
+	}
+
+	samples, err := bytesToSamples(data)
+	if err != nil {
+		return nil, err
+	}
+	if channels == 1 {
+		return samples, nil
+	}
+
+	mono := make([]float64, len(samples)/channels)
+	for i := range mono {
+		sum := 0.0
+		for c := 0; c < channels; c++ {
+			sum += samples[i*channels+c]
+		}
+		mono[i] = sum / float64(channels)
+	}
+
+	return mono, nil
+}
+
 // BytesToSamples converts a byte slice containing 16-bit PCM WAV audio data
 // into a slice of float64 samples scaled to the range [-1, 1].
 //
@@ -0,0 +1,44 @@
+package fingerprint
+
+import "testing"
+
+func TestDecodePCM16(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		channels int
+		want     []float64
+		wantErr  bool
+	}{
+		{name: "mono", data: []byte{0x00, 0x40, 0x00, 0xC0}, channels: 1, want: []float64{0.5, -0.5}},
+		{name: "full scale", data: []byte{0x00, 0x80, 0xFF, 0x7F}, channels: 1, want: []float64{-1, 32767.0 / 32768}},
+		{name: "stereo averaged", data: []byte{0x00, 0x40, 0x00, 0xC0, 0x00, 0x40, 0x00, 0x20}, channels: 2, want: []float64{0, 0.375}},
+		{name: "empty", data: []byte{}, channels: 2, want: []float64{}},
+		{name: "partial frame", data: []byte{0x00, 0x40, 0x00, 0xC0, 0x00, 0x40}, channels: 2, wantErr: true},
+		{name: "odd length", data: []byte{0x00}, channels: 1, wantErr: true},
+		{name: "no channels", data: []byte{0x00, 0x40}, channels: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := DecodePCM16(tt.data, tt.channels)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", samples)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(samples) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(samples), len(tt.want))
+			}
+			for i := range samples {
+				if samples[i] != tt.want[i] {
+					t.Errorf("sample %d is %v, want %v", i, samples[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
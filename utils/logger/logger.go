@@ -12,3 +12,7 @@ func Info(message string) {
 func Error(err error) {
 	fmt.Printf("[%s] ERROR: %s\n", time.Now().Format("15:04:05"), err)
 }
+
+func Warn(message string) {
+	fmt.Printf("[%s] WARN: %s\n", time.Now().Format("15:04:05"), message)
+}